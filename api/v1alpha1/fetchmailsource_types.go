@@ -0,0 +1,130 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FetchmailProtocol is the protocol used to retrieve the remote mails
+// +kubebuilder:validation:Enum=IMAP;POP3
+type FetchmailProtocol string
+
+const (
+	FetchmailProtocolIMAP FetchmailProtocol = "IMAP"
+	FetchmailProtocolPOP3 FetchmailProtocol = "POP3"
+)
+
+// FetchmailSourceSpec defines the desired state of FetchmailSource
+type FetchmailSourceSpec struct {
+	// MailServer is the name of the MailServer, in the same namespace, retrieving the mails
+	// +kubebuilder:validation:Required
+	MailServer string `json:"mailServer"`
+	// Server is the remote mail server host
+	// +kubebuilder:validation:Required
+	Server string `json:"server"`
+	// Port is the optional remote mail server port, defaults to the protocol port
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port *int32 `json:"port,omitempty"`
+	// Protocol is the protocol used to retrieve the mails
+	// +kubebuilder:default=IMAP
+	// +optional
+	Protocol FetchmailProtocol `json:"protocol,omitempty"`
+	// SSL enables the TLS connection to the remote mail server
+	// +kubebuilder:default=true
+	// +optional
+	SSL *bool `json:"ssl,omitempty"`
+	// CredentialsSecret is the name of the secret containing the remote mailbox credentials
+	// It expects the following keys:
+	// - username: the remote user name
+	// - password: the remote password
+	// +kubebuilder:validation:Required
+	CredentialsSecret string `json:"credentialsSecret"`
+	// Mailbox is the local mailbox receiving the mails, e.g. user@example.org
+	// +kubebuilder:validation:Required
+	Mailbox string `json:"mailbox"`
+	// Folders are the optional remote IMAP folders to retrieve, defaults to the inbox
+	// +optional
+	Folders []string `json:"folders,omitempty"`
+	// Keep keeps the retrieved mails on the remote server
+	// +optional
+	Keep bool `json:"keep,omitempty"`
+	// FetchAll retrieves all the mails, including the already seen ones
+	// +optional
+	FetchAll bool `json:"fetchAll,omitempty"`
+}
+
+// FetchmailPollResult is the result of the last remote mailbox connectivity probe
+// +kubebuilder:validation:Enum=Pending;Success;Failed
+type FetchmailPollResult string
+
+const (
+	// FetchmailPollPending means that the source was not polled yet, e.g. the mail server is not running
+	FetchmailPollPending FetchmailPollResult = "Pending"
+	// FetchmailPollSuccess means that the remote mailbox was reached
+	FetchmailPollSuccess FetchmailPollResult = "Success"
+	// FetchmailPollFailed means that the remote mailbox was not reached
+	FetchmailPollFailed FetchmailPollResult = "Failed"
+)
+
+// FetchmailSourceStatus defines the observed state of FetchmailSource
+type FetchmailSourceStatus struct {
+	// LastPollTime is the time of the last connectivity probe
+	// +optional
+	LastPollTime *metav1.Time `json:"lastPollTime,omitempty"`
+	// LastPollResult is the result of the last connectivity probe.
+	// The operator runs its own fetchmail --check against the remote mailbox, at most every 15 minutes:
+	// it reports whether the server is reachable and accepts the credentials, not the result of the
+	// mail server fetchmail daemon polls, which are only logged by the mail server.
+	// +optional
+	LastPollResult FetchmailPollResult `json:"lastPollResult,omitempty"`
+	// Message is the fetchmail output of the last failed probe
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=fetchmailsources,shortName=fms
+// +kubebuilder:printcolumn:name="Server",type=string,JSONPath=`.spec.server`
+// +kubebuilder:printcolumn:name="Mailbox",type=string,JSONPath=`.spec.mailbox`
+// +kubebuilder:printcolumn:name="Result",type=string,JSONPath=`.status.lastPollResult`
+// +kubebuilder:printcolumn:name="Last Poll",type="date",JSONPath=".status.lastPollTime"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="MailServer",type="string",priority=1,JSONPath=".spec.mailServer"
+
+// FetchmailSource is the Schema for the fetchmailsources API
+type FetchmailSource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FetchmailSourceSpec   `json:"spec,omitempty"`
+	Status FetchmailSourceStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// FetchmailSourceList contains a list of FetchmailSource
+type FetchmailSourceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []FetchmailSource `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&FetchmailSource{}, &FetchmailSourceList{})
+}
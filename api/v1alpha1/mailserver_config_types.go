@@ -0,0 +1,167 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+// SpamScore is a SpamAssassin score, e.g. 2.0 or 6.31
+// +kubebuilder:validation:Pattern=`^-?[0-9]+(\.[0-9]+)?$`
+type SpamScore string
+
+// Config is the tunable subset of the docker-mailserver environment configuration.
+// The settings managed by the operator, e.g. the hostname, the certificates or the features, are not exposed.
+// Unset values fall back to the operator defaults.
+// See https://docker-mailserver.github.io/docker-mailserver/edge/config/environment/ for the details.
+type Config struct {
+	// LogLevel is the docker-mailserver log level
+	// +kubebuilder:validation:Enum=error;warn;info;debug;trace
+	// +optional
+	LogLevel string `json:"logLevel,omitempty"`
+	// SupervisorLogLevel is the supervisord log level
+	// +kubebuilder:validation:Enum=critical;error;warn;info;debug
+	// +optional
+	SupervisorLogLevel string `json:"supervisorLogLevel,omitempty"`
+	// PostmasterAddress overrides the postmaster address, defaults to postmaster@<domain>
+	// Deprecated: use postmaster.address, which also creates the postmaster mailbox
+	// +optional
+	PostmasterAddress string `json:"postmasterAddress,omitempty"`
+	// EnableUpdateCheck sends a mail to the postmaster when an update is available
+	// +optional
+	EnableUpdateCheck *bool `json:"enableUpdateCheck,omitempty"`
+	// UpdateCheckInterval is the update check interval, e.g. 1d
+	// +kubebuilder:validation:Pattern=`^[0-9]+[smhd]$`
+	// +optional
+	UpdateCheckInterval string `json:"updateCheckInterval,omitempty"`
+	// PermitDocker sets the networks added to the Postfix mynetworks option
+	// +kubebuilder:validation:Enum=none;container;host;network;connected-networks
+	// +optional
+	PermitDocker string `json:"permitDocker,omitempty"`
+	// TZ is the container timezone, e.g. Europe/Paris
+	// +optional
+	TZ string `json:"tz,omitempty"`
+	// TLSLevel is the TLS protocols and ciphers level
+	// +kubebuilder:validation:Enum=modern;intermediate
+	// +optional
+	TLSLevel string `json:"tlsLevel,omitempty"`
+	// AmavisLogLevel is the Amavis log level, from -3 (errors only) to 5 (debug)
+	// +kubebuilder:validation:Minimum=-3
+	// +kubebuilder:validation:Maximum=5
+	// +optional
+	AmavisLogLevel *int `json:"amavisLogLevel,omitempty"`
+	// EnableDNSBL enables the DNS block lists in Postfix and Postscreen
+	// +optional
+	EnableDNSBL *bool `json:"enableDnsbl,omitempty"`
+	// Fail2banBlockType is the Fail2ban block type
+	// +kubebuilder:validation:Enum=drop;reject
+	// +optional
+	Fail2banBlockType string `json:"fail2banBlockType,omitempty"`
+	// PostscreenAction is the action taken when a Postscreen test fails, defaults to enforce
+	// +kubebuilder:validation:Enum=enforce;drop;ignore
+	// +optional
+	PostscreenAction string `json:"postscreenAction,omitempty"`
+	// ClamavMessageSizeLimit is the size above which messages are not scanned by ClamAV, e.g. 25M
+	// +kubebuilder:validation:Pattern=`^[0-9]+[KMG]?$`
+	// +optional
+	ClamavMessageSizeLimit string `json:"clamavMessageSizeLimit,omitempty"`
+	// VirusMailsDeleteDelay is the number of days a virus mail stays on the server before being deleted
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	VirusMailsDeleteDelay *int `json:"virusMailsDeleteDelay,omitempty"`
+	// PostfixMailboxSizeLimit is the mailbox size limit in bytes, 0 means unlimited
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PostfixMailboxSizeLimit *int64 `json:"postfixMailboxSizeLimit,omitempty"`
+	// PostfixMessageSizeLimit is the message size limit in bytes, 0 means unlimited
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PostfixMessageSizeLimit *int64 `json:"postfixMessageSizeLimit,omitempty"`
+	// PflogsummTrigger enables the pflogsumm reports
+	// +kubebuilder:validation:Enum=daily_cron;logrotate
+	// +optional
+	PflogsummTrigger string `json:"pflogsummTrigger,omitempty"`
+	// PflogsummRecipient is the pflogsumm reports recipient
+	// +optional
+	PflogsummRecipient string `json:"pflogsummRecipient,omitempty"`
+	// PflogsummSender is the pflogsumm reports sender
+	// +optional
+	PflogsummSender string `json:"pflogsummSender,omitempty"`
+	// LogwatchInterval is the logwatch reports interval
+	// +kubebuilder:validation:Enum=none;daily;weekly
+	// +optional
+	LogwatchInterval string `json:"logwatchInterval,omitempty"`
+	// LogwatchRecipient is the logwatch reports recipient
+	// +optional
+	LogwatchRecipient string `json:"logwatchRecipient,omitempty"`
+	// LogwatchSender is the logwatch reports sender
+	// +optional
+	LogwatchSender string `json:"logwatchSender,omitempty"`
+	// ReportRecipient is the default reports recipient
+	// +optional
+	ReportRecipient string `json:"reportRecipient,omitempty"`
+	// ReportSender is the default reports sender
+	// +optional
+	ReportSender string `json:"reportSender,omitempty"`
+	// LogrotateInterval is the log files rotation interval
+	// +kubebuilder:validation:Enum=daily;weekly;monthly
+	// +optional
+	LogrotateInterval string `json:"logrotateInterval,omitempty"`
+	// PostfixInetProtocols are the IP protocols used by Postfix
+	// +kubebuilder:validation:Enum=all;ipv4;ipv6
+	// +optional
+	PostfixInetProtocols string `json:"postfixInetProtocols,omitempty"`
+	// DovecotInetProtocols are the IP protocols used by Dovecot
+	// +kubebuilder:validation:Enum=all;ipv4;ipv6
+	// +optional
+	DovecotInetProtocols string `json:"dovecotInetProtocols,omitempty"`
+	// DovecotMailboxFormat is the mailbox format
+	// +kubebuilder:validation:Enum=maildir;sdbox;mdbox
+	// +optional
+	DovecotMailboxFormat string `json:"dovecotMailboxFormat,omitempty"`
+	// SpamassassinSpamToInbox delivers the spam messages to the inbox, defaults to true
+	// +optional
+	SpamassassinSpamToInbox *bool `json:"spamassassinSpamToInbox,omitempty"`
+	// MoveSpamToJunk moves the spam messages to the Junk folder, defaults to true, requires SpamassassinSpamToInbox
+	// +optional
+	MoveSpamToJunk *bool `json:"moveSpamToJunk,omitempty"`
+	// SATag is the score at, or above, which the spam info headers are added, defaults to 2.0
+	// +optional
+	SATag *SpamScore `json:"saTag,omitempty"`
+	// SATag2 is the score at which the spam detected headers are added, defaults to 6.31
+	// +optional
+	SATag2 *SpamScore `json:"saTag2,omitempty"`
+	// SAKill is the score triggering the spam evasive actions, defaults to 6.31
+	// +optional
+	SAKill *SpamScore `json:"saKill,omitempty"`
+	// SASpamSubject is the tag added to the subject of the spam messages
+	// +optional
+	SASpamSubject *string `json:"saSpamSubject,omitempty"`
+	// FetchmailPoll is the interval in seconds between the FetchmailSources polls, defaults to 300
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FetchmailPoll *int `json:"fetchmailPoll,omitempty"`
+	// PostgreyDelay is the greylisting delay in seconds, defaults to 300
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PostgreyDelay *int `json:"postgreyDelay,omitempty"`
+	// PostgreyMaxAge is the number of days after which unseen entries are deleted, defaults to 35
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PostgreyMaxAge *int `json:"postgreyMaxAge,omitempty"`
+	// PostgreyText is the response sent when a mail is greylisted
+	// +optional
+	PostgreyText string `json:"postgreyText,omitempty"`
+	// PostgreyAutoWhitelistClients whitelists a host after N successful deliveries, 0 disables the whitelisting
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PostgreyAutoWhitelistClients *int `json:"postgreyAutoWhitelistClients,omitempty"`
+}
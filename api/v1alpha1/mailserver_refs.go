@@ -0,0 +1,73 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+// SecretRefs returns the names of the Secrets referenced by the spec, new Secret references must be added here
+// so that their changes trigger the MailServer reconciliation
+func (s *MailServer) SecretRefs() []string {
+	var refs []string
+	add := func(names ...string) {
+		for _, v := range names {
+			if v != "" {
+				refs = append(refs, v)
+			}
+		}
+	}
+	add(s.Spec.Postmaster.ExistingSecret)
+	f := s.Spec.Features
+	if f.LDAP.Enabled {
+		add(f.LDAP.BindSecret, f.LDAP.TLS.ClientCertSecret)
+		if f.LDAP.TLS.CA != nil && f.LDAP.TLS.CA.Secret != nil {
+			add(f.LDAP.TLS.CA.Secret.Name)
+		}
+	}
+	if s.Spec.Relay != nil {
+		add(s.Spec.Relay.CredentialsSecret)
+		for _, v := range s.Spec.Relay.Domains {
+			add(v.CredentialsSecret)
+		}
+	}
+	if f.Rspamd.Enabled {
+		add(f.Rspamd.Redis.PasswordSecret, f.Rspamd.WebUI.PasswordSecret)
+	}
+	if f.OIDC.Enabled && f.OIDC.ClientSecret != nil {
+		add(f.OIDC.ClientSecret.Name)
+	}
+	return refs
+}
+
+// ConfigMapRefs returns the names of the ConfigMaps referenced by the spec, new ConfigMap references must be added here
+// so that their changes trigger the MailServer reconciliation
+func (s *MailServer) ConfigMapRefs() []string {
+	var refs []string
+	for _, v := range []*ConfigMapKeyRef{
+		s.Spec.Overrides.PostfixMain,
+		s.Spec.Overrides.PostfixMaster,
+		s.Spec.Overrides.Dovecot,
+		s.Spec.Overrides.PolicydSPF,
+	} {
+		if v != nil {
+			refs = append(refs, v.Name)
+		}
+	}
+	f := s.Spec.Features
+	if f.LDAP.Enabled && f.LDAP.TLS.CA != nil && f.LDAP.TLS.CA.ConfigMap != nil {
+		refs = append(refs, f.LDAP.TLS.CA.ConfigMap.Name)
+	}
+	for _, v := range f.SpamassassinConfig.Rules {
+		refs = append(refs, v.Name)
+	}
+	return refs
+}
@@ -75,7 +75,23 @@ type MailServerSpec struct {
 	// +optional
 	Features Features `json:"features,omitempty"`
 
-	// TODO(adphi): add custom config mounts support for the MailServer Deployment
+	// Config is the optional docker-mailserver configuration, merged over the operator defaults
+	// +optional
+	Config Config `json:"config,omitempty"`
+
+	// Postmaster is the optional postmaster mailbox configuration
+	// +optional
+	Postmaster PostmasterConfig `json:"postmaster,omitempty"`
+
+	// Relay is the optional outbound relay configuration, all the outgoing mails are sent through it
+	// +optional
+	Relay *RelayConfig `json:"relay,omitempty"`
+
+	// Overrides are the optional docker-mailserver configuration files overrides.
+	// They are only applied once validated, the result being reported in the OverridesValid condition.
+	// +optional
+	Overrides Overrides `json:"overrides,omitempty"`
+
 	// Volume is the optional volume configuration
 	// +optional
 	Volume VolumeConfig `json:"volume,omitempty"`
@@ -83,6 +99,20 @@ type MailServerSpec struct {
 	// Traefik is the optional Traefik configuration
 	// +optional
 	Traefik *TraefikConfig `json:"traefik,omitempty"`
+
+	// Monitoring is the optional metrics configuration
+	// +optional
+	Monitoring MonitoringConfig `json:"monitoring,omitempty"`
+
+	// Paused stops the reconciliation of the mail server resources, e.g. to edit them by hand during an incident.
+	// The status is still updated.
+	// The mail.linka.cloud/paused="true" annotation has the same effect.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+	// Maintenance flushes the Postfix queue, then scales the mail server down to zero
+	// and makes the autoconfig service answer with a maintenance response.
+	// +optional
+	Maintenance bool `json:"maintenance,omitempty"`
 }
 
 type Features struct {
@@ -98,6 +128,11 @@ type Features struct {
 	// +optional
 	// +kubebuilder:default=false
 	Clamav *bool `json:"clamav,omitempty"`
+	// ClamavServer is the optional standalone ClamAV configuration.
+	// If set, ClamAV runs in its own deployment instead of in every mail server pod,
+	// and Amavis or Rspamd scan the mails over TCP. It requires clamav to be enabled.
+	// +optional
+	ClamavServer *ClamavServerConfig `json:"clamavServer,omitempty"`
 	// Amavis enables amaivs
 	// +optional
 	// +kubebuilder:default=true
@@ -122,15 +157,29 @@ type Features struct {
 	// +optional
 	// +kubebuilder:default=false
 	SpamassassinKam *bool `json:"spamassassinKam,omitempty"`
+	// SpamassassinConfig is the optional SpamAssassin configuration
+	// +optional
+	SpamassassinConfig SpamassassinConfig `json:"spamassassinConfig,omitempty"`
 	// Postgrey enables postgrey
 	// +optional
 	// +kubebuilder:default=false
 	Postgrey *bool `json:"postgrey,omitempty"`
+	// SRS is the optional Sender Rewriting Scheme configuration
+	// +optional
+	SRS SRSConfig `json:"srs,omitempty"`
+	// Rspamd is the optional Rspamd configuration, replacing Amavis, SpamAssassin, OpenDKIM, OpenDMARC and policyd-spf
+	// +optional
+	Rspamd RspamdConfig `json:"rspamd,omitempty"`
 	// LDAP is the optional LDAP configuration
-	// It expects an Active Directory like server
+	// The filters default to the Active Directory ones, see the preset
 	// LDAP is not supported yet
 	// +optional
 	LDAP LDAPConfig `json:"ldap,omitempty"`
+	// OIDC is the optional OAuth2 authentication configuration, adding the XOAUTH2 and OAUTHBEARER mechanisms
+	// to IMAP, POP3 and SMTP submission. The accounts are still provisioned by the file or LDAP provisioner.
+	// The OAuth2 settings are advertised in the Thunderbird autoconfig configuration served by the autoconfig proxy.
+	// +optional
+	OIDC OIDCConfig `json:"oidc,omitempty"`
 }
 
 type AutoConfig struct {
@@ -152,6 +201,30 @@ type AutoConfigDeployment struct {
 	// +kubebuilder:validation:Required
 	// +kubebuilder:default="docker.io/linkacloud/autoconfig:latest"
 	Image string `json:"image,omitempty"`
+	// ProxyImage is the nginx image answering in front of the autoconfig image, e.g. with the maintenance response
+	// +optional
+	// +kubebuilder:default="docker.io/nginxinc/nginx-unprivileged:1.23-alpine"
+	ProxyImage string `json:"proxyImage,omitempty"`
+}
+
+// ComponentDeployment is the scheduling and resources configuration of the operator managed components,
+// e.g. the Redis and ClamAV deployments
+type ComponentDeployment struct {
+	// Resources is the optional container resource configuration
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Affinity is the optional affinity configuration
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// TopologySpreadConstraints is the optional topology spread constraints configuration
+	// +optional
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	// Tolerations is the optional tolerations configuration
+	// +optional
+	Tolerations []corev1.Toleration `json:"toleration,omitempty"`
+	// NodeSelector is the optional node selector configuration
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
 }
 
 type DeploymentConfig struct {
@@ -168,6 +241,7 @@ type DeploymentConfig struct {
 	// +optional
 	Affinity *corev1.Affinity `json:"affinity,omitempty"`
 	// Strategy is the deployment strategy to use to replace existing pods with new ones.
+	// The mail server defaults to Recreate so that two pods never write the same mail data at once.
 	// +optional
 	Strategy appsv1.DeploymentStrategy `json:"strategy,omitempty"`
 	// SecurityContext is the optional security context for the deployment
@@ -193,6 +267,46 @@ type DeploymentConfig struct {
 	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
 	// Volumes is the optional extra volumes configuration for the deployment
 	Volumes []corev1.Volume `json:"volumes,omitempty"`
+	// TerminationGracePeriodSeconds is the optional duration the pod is given to terminate gracefully,
+	// the mail server defaults to 120 seconds to let the Postfix queue drain
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+	// Probes is the optional probes timings configuration, only used by the mail server container
+	// +optional
+	Probes ProbesConfig `json:"probes,omitempty"`
+}
+
+type ProbesConfig struct {
+	// Startup overrides the startup probe timings,
+	// it defaults to 10 minutes to tolerate the slow ClamAV and SpamAssassin boot
+	// +optional
+	Startup ProbeConfig `json:"startup,omitempty"`
+	// Liveness overrides the liveness probe timings
+	// +optional
+	Liveness ProbeConfig `json:"liveness,omitempty"`
+	// Readiness overrides the readiness probe timings
+	// +optional
+	Readiness ProbeConfig `json:"readiness,omitempty"`
+}
+
+type ProbeConfig struct {
+	// InitialDelaySeconds is the number of seconds after the container has started before the probe is initiated
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	InitialDelaySeconds *int32 `json:"initialDelaySeconds,omitempty"`
+	// PeriodSeconds is how often to perform the probe
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PeriodSeconds *int32 `json:"periodSeconds,omitempty"`
+	// TimeoutSeconds is the number of seconds after which the probe times out
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+	// FailureThreshold is the number of consecutive failures for the probe to be considered failed
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
 }
 
 type TraefikConfig struct {
@@ -214,6 +328,65 @@ type TraefikEntrypoints struct {
 	HTTPS string `json:"https,omitempty"`
 }
 
+type MonitoringConfig struct {
+	// Exporter is the optional Postfix and Dovecot metrics exporters configuration
+	// +optional
+	Exporter ExporterConfig `json:"exporter,omitempty"`
+}
+
+type ExporterConfig struct {
+	// Enabled injects the Postfix and Dovecot exporters sidecars in the mail server pods
+	// and exposes their metrics with a dedicated ClusterIP service
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+	// Postfix is the Postfix exporter sidecar configuration,
+	// the image defaults to docker.io/boky/postfix-exporter:latest
+	// +optional
+	Postfix ExporterContainer `json:"postfix,omitempty"`
+	// Dovecot is the Dovecot exporter sidecar configuration,
+	// the image defaults to docker.io/kumina/dovecot-exporter:latest
+	// +optional
+	Dovecot ExporterContainer `json:"dovecot,omitempty"`
+	// ServiceMonitor is the optional Prometheus Operator ServiceMonitor configuration
+	// +optional
+	ServiceMonitor ServiceMonitorConfig `json:"serviceMonitor,omitempty"`
+	// PrometheusRule is the optional Prometheus Operator baseline alerting rules configuration
+	// +optional
+	PrometheusRule PrometheusRuleConfig `json:"prometheusRule,omitempty"`
+}
+
+type ExporterContainer struct {
+	// Image is the exporter image to use
+	// +optional
+	Image string `json:"image,omitempty"`
+	// Resources is the optional exporter container resource configuration
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+type ServiceMonitorConfig struct {
+	// Enabled creates the ServiceMonitor scraping the exporters, it requires the Prometheus Operator CRDs
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+	// Labels are the optional labels added to the ServiceMonitor, e.g. to match the Prometheus serviceMonitorSelector
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Interval is the optional scrape interval, e.g. 30s
+	// +kubebuilder:validation:Pattern=`^([0-9]+(ms|s|m|h))+$`
+	// +optional
+	Interval string `json:"interval,omitempty"`
+}
+
+type PrometheusRuleConfig struct {
+	// Enabled creates the baseline alerting rules: queue growth, bounce spike and certificate expiry.
+	// It requires the Prometheus Operator CRDs.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+	// Labels are the optional labels added to the PrometheusRule, e.g. to match the Prometheus ruleSelector
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
 type IngressConfig struct {
 	// Annotations is the optional annotations to add to the ingress
 	// +optional
@@ -235,6 +408,148 @@ type VolumeConfig struct {
 	AccessMode corev1.PersistentVolumeAccessMode `json:"accessMode,omitempty"`
 }
 
+// SRSSenderClass is the sender address rewritten by the Sender Rewriting Scheme
+// +kubebuilder:validation:Enum=envelope_sender;header_sender
+type SRSSenderClass string
+
+const (
+	// SRSEnvelopeSender rewrites the envelope sender address
+	SRSEnvelopeSender SRSSenderClass = "envelope_sender"
+	// SRSHeaderSender rewrites the header sender address
+	SRSHeaderSender SRSSenderClass = "header_sender"
+)
+
+type SRSConfig struct {
+	// Enabled enables the Sender Rewriting Scheme, needed when forwarding mails so that SPF does not break downstream.
+	// The SRS secret is generated once and kept in the srs-<domain> secret, it can be rotated by setting
+	// the mail.linka.cloud/rotate-srs-secret annotation to a new value, the previous secrets are kept for validation.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+	// ExcludeDomains are the optional sender domains that are not rewritten
+	// +optional
+	ExcludeDomains []string `json:"excludeDomains,omitempty"`
+	// SenderClasses are the sender addresses to rewrite, defaults to envelope_sender
+	// +optional
+	SenderClasses []SRSSenderClass `json:"senderClasses,omitempty"`
+}
+
+// SpamassassinConfig configures the SpamAssassin sender lists, rules and learning,
+// the spam thresholds, subject tag and inbox delivery are set with the config saTag, saTag2, saKill,
+// saSpamSubject and spamassassinSpamToInbox fields
+type SpamassassinConfig struct {
+	// Allow is the list of sender domains or addresses whose mails are never marked as spam
+	// +optional
+	Allow []string `json:"allow,omitempty"`
+	// Deny is the list of sender domains or addresses whose mails are always marked as spam
+	// +optional
+	Deny []string `json:"deny,omitempty"`
+	// Rules are the optional ConfigMaps containing extra SpamAssassin rules,
+	// every key ending with .cf is appended to the spamassassin-rules.cf file, sorted by ConfigMap name and key
+	// +optional
+	Rules []corev1.LocalObjectReference `json:"rules,omitempty"`
+	// Learning is the optional Bayes learning configuration, training the Bayes database from the users mail folders
+	// +optional
+	Learning *SpamLearningConfig `json:"learning,omitempty"`
+}
+
+type SpamLearningConfig struct {
+	// Schedule is the learning CronJob schedule in the cron format
+	// +optional
+	// +kubebuilder:default="0 3 * * *"
+	Schedule string `json:"schedule,omitempty"`
+	// SpamFolders are the folders containing the mails learned as spam, defaults to Junk
+	// +optional
+	SpamFolders []string `json:"spamFolders,omitempty"`
+	// HamFolders are the folders containing the mails learned as ham, defaults to INBOX and Archive
+	// +optional
+	HamFolders []string `json:"hamFolders,omitempty"`
+	// MaxAgeDays is the age in days of the oldest mails to learn from
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=30
+	MaxAgeDays *int32 `json:"maxAgeDays,omitempty"`
+}
+
+type ClamavServerConfig struct {
+	// Replicas is the number of clamd replicas
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Deployment is the ClamAV deployment configuration
+	// +optional
+	Deployment ClamavDeployment `json:"deployment,omitempty"`
+	// Cache is the optional signatures volume shared by the replicas, the signatures are then downloaded
+	// by a single freshclam deployment. If unset, each replica downloads its own signatures in an emptyDir.
+	// +optional
+	Cache *VolumeConfig `json:"cache,omitempty"`
+	// Mirror is the optional freshclam database mirror, defaults to database.clamav.net
+	// +optional
+	Mirror string `json:"mirror,omitempty"`
+}
+
+type ClamavDeployment struct {
+	ComponentDeployment `json:",inline"`
+	// Image is the ClamAV image to use
+	// +optional
+	// +kubebuilder:default="docker.io/clamav/clamav:stable"
+	Image string `json:"image,omitempty"`
+}
+
+// RspamdConfig requires a docker-mailserver image version 13.0.0 or later, the default image does not support it:
+// the image must be set explicitly when Rspamd is enabled.
+type RspamdConfig struct {
+	// Enabled enables Rspamd as the filtering stack.
+	// OpenDKIM, OpenDMARC and policyd-spf are disabled, Amavis and SpamAssassin must be disabled explicitly.
+	// It requires a docker-mailserver image version 13.0.0 or later.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+	// Redis is the Redis backend configuration, an operator managed Redis deployment is used if no external address is set
+	// +optional
+	Redis RspamdRedisConfig `json:"redis,omitempty"`
+	// DKIMSigning signs the outgoing mails with the operator DKIM keys
+	// +optional
+	// +kubebuilder:default=true
+	DKIMSigning *bool `json:"dkimSigning,omitempty"`
+	// WebUI is the optional Rspamd web interface configuration
+	// +optional
+	WebUI RspamdWebUIConfig `json:"webUI,omitempty"`
+}
+
+type RspamdRedisConfig struct {
+	// External is the optional external Redis address, e.g. redis.example.svc.cluster.local:6379
+	// +optional
+	External string `json:"external,omitempty"`
+	// PasswordSecret is the optional name of the secret containing the external Redis password in the password key
+	// +optional
+	PasswordSecret string `json:"passwordSecret,omitempty"`
+	// Deployment is the operator managed Redis deployment configuration.
+	// The data is not persisted, use an external Redis to keep the learned data across restarts.
+	// +optional
+	Deployment RedisDeployment `json:"deployment,omitempty"`
+}
+
+type RedisDeployment struct {
+	ComponentDeployment `json:",inline"`
+	// Image is the Redis image to use
+	// +optional
+	// +kubebuilder:default="docker.io/library/redis:7-alpine"
+	Image string `json:"image,omitempty"`
+}
+
+type RspamdWebUIConfig struct {
+	// Enabled exposes the Rspamd web interface on rspamd.<domain>
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+	// PasswordSecret is the name of the secret containing the web interface password in the password key,
+	// it is required when the web interface is enabled
+	// +optional
+	PasswordSecret string `json:"passwordSecret,omitempty"`
+	// Ingress is the optional ingress configuration
+	// +optional
+	Ingress IngressConfig `json:"ingress,omitempty"`
+}
+
 type LDAPConfig struct {
 	// Enabled enables the LDAP configuration
 	// +optional
@@ -262,9 +577,261 @@ type LDAPConfig struct {
 	// SearchBase is the LDAP base where to search for users
 	// +kubebuilder:validation:Required
 	SearchBase string `json:"searchBase,omitempty"`
-	// SearchFilter is the LDAP filter to use to search for users
-	// +kubebuilder:validation:Required
+	// UserFilter is the LDAP filter to use to search for users
+	// Deprecated: use filters.user, which takes precedence
+	// +optional
 	UserFilter string `json:"userFilter,omitempty"`
+
+	// TLS is the optional TLS configuration used by Postfix, Dovecot and saslauthd to connect to the LDAP server
+	// +optional
+	TLS LDAPTLSConfig `json:"tls,omitempty"`
+
+	// Preset is the directory schema the default filters are chosen for
+	// +optional
+	// +kubebuilder:default=activeDirectory
+	Preset LDAPPreset `json:"preset,omitempty"`
+	// Filters are the optional filters overriding the preset ones
+	// +optional
+	Filters LDAPFilters `json:"filters,omitempty"`
+}
+
+type LDAPTLSConfig struct {
+	// CA is the optional CA bundle used to verify the LDAP server certificate,
+	// the image CA certificates are used if unset
+	// +optional
+	CA *LDAPCABundle `json:"ca,omitempty"`
+	// ClientCertSecret is the optional name of the kubernetes.io/tls secret
+	// containing the client certificate and key used for mutual TLS
+	// +optional
+	ClientCertSecret string `json:"clientCertSecret,omitempty"`
+	// InsecureSkipVerify disables the LDAP server certificate verification
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// LDAPCABundle references the CA bundle either in a Secret or in a ConfigMap
+type LDAPCABundle struct {
+	// Secret is the optional Secret key containing the CA bundle
+	// +optional
+	Secret *SecretKeyRef `json:"secret,omitempty"`
+	// ConfigMap is the optional ConfigMap key containing the CA bundle
+	// +optional
+	ConfigMap *ConfigMapKeyRef `json:"configMap,omitempty"`
+}
+
+// LDAPPreset is a directory schema with known default filters
+// +kubebuilder:validation:Enum=activeDirectory;openldap;freeipa;lldap
+type LDAPPreset string
+
+const (
+	LDAPPresetActiveDirectory LDAPPreset = "activeDirectory"
+	LDAPPresetOpenLDAP        LDAPPreset = "openldap"
+	LDAPPresetFreeIPA         LDAPPreset = "freeipa"
+	LDAPPresetLLDAP           LDAPPreset = "lldap"
+)
+
+type LDAPFilters struct {
+	// User is the Postfix users filter, it must contain the %s address placeholder
+	// +optional
+	User string `json:"user,omitempty"`
+	// Group is the Postfix groups filter, it must contain the %s address placeholder
+	// +optional
+	Group string `json:"group,omitempty"`
+	// Alias is the Postfix aliases filter, it must contain the %s address placeholder
+	// +optional
+	Alias string `json:"alias,omitempty"`
+	// Domain is the Postfix domains filter, it must contain the %s domain placeholder
+	// +optional
+	Domain string `json:"domain,omitempty"`
+	// DovecotUser is the Dovecot user lookup filter, it must contain the %u user placeholder
+	// +optional
+	DovecotUser string `json:"dovecotUser,omitempty"`
+	// DovecotPass is the Dovecot password lookup filter, it must contain the %u user placeholder
+	// +optional
+	DovecotPass string `json:"dovecotPass,omitempty"`
+	// DovecotUserAttrs is the Dovecot user attributes mapping
+	// +optional
+	DovecotUserAttrs string `json:"dovecotUserAttrs,omitempty"`
+	// SASL is the saslauthd filter, it must contain the %u or %U user placeholder
+	// +optional
+	SASL string `json:"sasl,omitempty"`
+}
+
+type OIDCConfig struct {
+	// Enabled enables the OAuth2 authentication
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+	// Issuer is the OpenID Connect issuer URL advertised to the mail clients,
+	// e.g. https://keycloak.example.org/realms/example
+	// +optional
+	Issuer string `json:"issuer,omitempty"`
+	// AuthorizationURL is the authorization endpoint advertised to the mail clients,
+	// defaults to the Keycloak one under the issuer: <issuer>/protocol/openid-connect/auth
+	// +optional
+	AuthorizationURL string `json:"authorizationURL,omitempty"`
+	// TokenURL is the token endpoint advertised to the mail clients,
+	// defaults to the Keycloak one under the issuer: <issuer>/protocol/openid-connect/token
+	// +optional
+	TokenURL string `json:"tokenURL,omitempty"`
+	// Scopes are the scopes requested by the mail clients
+	// +optional
+	// +kubebuilder:default={openid,email}
+	Scopes []string `json:"scopes,omitempty"`
+	// ClientID is the OAuth2 client ID used to authenticate the introspection requests
+	// +optional
+	ClientID string `json:"clientID,omitempty"`
+	// ClientSecret references the OAuth2 client secret used to authenticate the introspection requests
+	// +optional
+	ClientSecret *SecretKeyRef `json:"clientSecret,omitempty"`
+	// IntrospectionURL is the token introspection endpoint,
+	// e.g. https://keycloak.example.org/realms/example/protocol/openid-connect/token/introspect
+	// +optional
+	IntrospectionURL string `json:"introspectionURL,omitempty"`
+	// UsernameClaim is the introspection response claim matched against the mailbox address
+	// +optional
+	// +kubebuilder:default=email
+	UsernameClaim string `json:"usernameClaim,omitempty"`
+}
+
+type Overrides struct {
+	// PostfixMain is the optional postfix-main.cf override, validated with postconf before rollout
+	// +optional
+	PostfixMain *ConfigMapKeyRef `json:"postfixMain,omitempty"`
+	// PostfixMaster is the optional postfix-master.cf override, validated with postconf before rollout
+	// +optional
+	PostfixMaster *ConfigMapKeyRef `json:"postfixMaster,omitempty"`
+	// Dovecot is the optional dovecot.cf override
+	// +optional
+	Dovecot *ConfigMapKeyRef `json:"dovecot,omitempty"`
+	// PolicydSPF is the optional postfix-policyd-spf.conf override
+	// +optional
+	PolicydSPF *ConfigMapKeyRef `json:"policydSPF,omitempty"`
+}
+
+// ConfigMapKeyRef references a key of a ConfigMap in the MailServer namespace
+type ConfigMapKeyRef struct {
+	// Name is the ConfigMap name
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// Key is the ConfigMap key
+	// +kubebuilder:validation:Required
+	Key string `json:"key"`
+}
+
+type PostmasterConfig struct {
+	// Address overrides the postmaster mailbox address, defaults to postmaster@<domain>
+	// +optional
+	Address string `json:"address,omitempty"`
+	// ExistingSecret is the optional name of a secret containing the postmaster password in its password key,
+	// e.g. synced by External Secrets. The operator generates the postmaster-<domain> secret if unset.
+	// +optional
+	ExistingSecret string `json:"existingSecret,omitempty"`
+	// RotationInterval is the optional interval after which the generated password is rotated, e.g. 720h.
+	// It cannot be used with existingSecret, which is rotated by its owner.
+	// +optional
+	RotationInterval *metav1.Duration `json:"rotationInterval,omitempty"`
+}
+
+// SecretKeyRef references a key of a Secret in the MailServer namespace
+type SecretKeyRef struct {
+	// Name is the Secret name
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// Key is the Secret key
+	// +kubebuilder:validation:Required
+	Key string `json:"key"`
+}
+
+// RelayTLSMode is the TLS mode used to connect to the relay host
+// +kubebuilder:validation:Enum=Opportunistic;StartTLS;Implicit
+type RelayTLSMode string
+
+const (
+	// RelayTLSOpportunistic uses STARTTLS when offered by the relay host
+	RelayTLSOpportunistic RelayTLSMode = "Opportunistic"
+	// RelayTLSStartTLS requires STARTTLS
+	RelayTLSStartTLS RelayTLSMode = "StartTLS"
+	// RelayTLSImplicit uses TLS from the start of the connection, e.g. on port 465
+	RelayTLSImplicit RelayTLSMode = "Implicit"
+)
+
+type RelayConfig struct {
+	// Host is the relay host
+	// +kubebuilder:validation:Required
+	Host string `json:"host"`
+	// Port is the relay port
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=587
+	// +optional
+	Port *int32 `json:"port,omitempty"`
+	// TLS is the TLS mode used to connect to the relay hosts
+	// +kubebuilder:default=StartTLS
+	// +optional
+	TLS RelayTLSMode `json:"tls,omitempty"`
+	// CredentialsSecret is the optional name of the secret containing the relay credentials
+	// It expects the following keys:
+	// - username: the relay user name
+	// - password: the relay password
+	// +optional
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+	// Domains are the optional per sender domain relays
+	// +optional
+	Domains []RelayDomain `json:"domains,omitempty"`
+}
+
+type RelayDomain struct {
+	// Domain is the sender domain
+	// +kubebuilder:validation:Required
+	Domain string `json:"domain"`
+	// Host is the relay host used for the sender domain
+	// +kubebuilder:validation:Required
+	Host string `json:"host"`
+	// Port is the relay port
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=587
+	// +optional
+	Port *int32 `json:"port,omitempty"`
+	// CredentialsSecret is the optional name of the secret containing the relay credentials,
+	// with the same keys as the default relay credentials secret
+	// +optional
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+}
+
+// Mode is the mail server operating mode
+// +kubebuilder:validation:Enum=Running;Paused;Draining;Maintenance
+type Mode string
+
+const (
+	// ModeRunning is the normal operating mode
+	ModeRunning Mode = "Running"
+	// ModePaused means that the mail server resources are not reconciled
+	ModePaused Mode = "Paused"
+	// ModeDraining means that the Postfix queue is being flushed before entering maintenance
+	ModeDraining Mode = "Draining"
+	// ModeMaintenance means that the mail server is scaled down to zero
+	ModeMaintenance Mode = "Maintenance"
+)
+
+// RestartPhase is the phase of an orchestrated mail server restart
+// +kubebuilder:validation:Enum=Draining;Rolling
+type RestartPhase string
+
+const (
+	// RestartPhaseDraining means that new connections are refused while the Postfix queue is being flushed
+	RestartPhaseDraining RestartPhase = "Draining"
+	// RestartPhaseRolling means that the mail server pods are being replaced
+	RestartPhaseRolling RestartPhase = "Rolling"
+)
+
+// RestartStatus reports the progress of an orchestrated mail server restart
+type RestartStatus struct {
+	// Phase is the current restart phase
+	Phase RestartPhase `json:"phase"`
+	// StartTime is the time the current phase started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
 }
 
 // MailServerStatus defines the observed state of MailServer
@@ -276,6 +843,60 @@ type MailServerStatus struct {
 	LoadBalancerIP string `json:"loadBalancerIP,omitempty"`
 	Traefik        *bool  `json:"traefik"`
 	AutoConfig     *bool  `json:"autoconfig"`
+	// Mode is the current operating mode
+	Mode Mode `json:"mode,omitempty"`
+	// ModeTransitionTime is the last time the mode changed
+	ModeTransitionTime *metav1.Time `json:"modeTransitionTime,omitempty"`
+	// OverridesChecksum is the checksum of the last validated configuration overrides
+	OverridesChecksum string `json:"overridesChecksum,omitempty"`
+	// Restart is the progress of the pending mail server restart, if any
+	Restart *RestartStatus `json:"restart,omitempty"`
+	// Postmaster is the postmaster password last applied to the mailbox
+	Postmaster *PostmasterStatus `json:"postmaster,omitempty"`
+	// SpamLearning is the status of the Bayes learning CronJob
+	SpamLearning *SpamLearningStatus `json:"spamLearning,omitempty"`
+	// LDAP is the last LDAP preflight check, its result is reported in the LDAPReady condition
+	LDAP *LDAPStatus `json:"ldap,omitempty"`
+	// Conditions are the latest observations of the mail server spec and dependencies, e.g. Valid or LDAPReady
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// SpamLearningResult is the result of the last Bayes learning run
+// +kubebuilder:validation:Enum=Running;Succeeded;Failed
+type SpamLearningResult string
+
+const (
+	SpamLearningRunning   SpamLearningResult = "Running"
+	SpamLearningSucceeded SpamLearningResult = "Succeeded"
+	SpamLearningFailed    SpamLearningResult = "Failed"
+)
+
+type SpamLearningStatus struct {
+	// LastScheduleTime is the last time the learning job was scheduled
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// LastSuccessfulTime is the last time the learning job completed successfully
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+	// LastResult is the result of the last learning run
+	LastResult SpamLearningResult `json:"lastResult,omitempty"`
+}
+
+type LDAPStatus struct {
+	// BindSecretResourceVersion is the resource version of the bind credentials secret last checked
+	BindSecretResourceVersion string `json:"bindSecretResourceVersion,omitempty"`
+	// LastCheckTime is the last time the LDAP server was checked
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
+}
+
+type PostmasterStatus struct {
+	// Address is the postmaster mailbox address
+	Address string `json:"address,omitempty"`
+	// SecretResourceVersion is the resource version of the password secret applied to the mailbox
+	SecretResourceVersion string `json:"secretResourceVersion,omitempty"`
+	// LastRotationTime is the last time the generated password was rotated
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -284,6 +905,7 @@ type MailServerStatus struct {
 // +kubebuilder:resource:path=mailservers,shortName=ms;mail
 // +kubebuilder:printcolumn:name="Domain",type=string,JSONPath=`.status.domain`
 // +kubebuilder:printcolumn:name="Capacity",type=string,JSONPath=`.status.volumeSize`
+// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.status.mode`
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:printcolumn:name="IP",type="string",priority=1,JSONPath=".status.loadBalancerIP"
 // +kubebuilder:printcolumn:name="Image",type="string",priority=1,JSONPath=".spec.image"
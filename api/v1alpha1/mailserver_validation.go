@@ -0,0 +1,275 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Validate checks the constraints that cannot be expressed in the CRD schema
+func (s *MailServer) Validate() error {
+	if err := s.Spec.Config.Validate(); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if err := s.Spec.Features.Validate(); err != nil {
+		return fmt.Errorf("features: %w", err)
+	}
+	if s.Spec.Features.Rspamd.Enabled && !imageAtLeast(s.Spec.Image, RspamdMinImageVersion) {
+		return fmt.Errorf("features: rspamd requires a docker-mailserver image version %s or later, got %s", RspamdMinImageVersion, s.Spec.Image)
+	}
+	if err := s.Spec.Features.SpamassassinConfig.Validate(); err != nil {
+		return fmt.Errorf("features: spamassassinConfig: %w", err)
+	}
+	// the learning job mounts the mail volume alongside the mail server pods
+	if s.Spec.Features.SpamassassinConfig.Learning != nil && s.Spec.Volume.AccessMode != "" && s.Spec.Volume.AccessMode != corev1.ReadWriteMany {
+		return fmt.Errorf("features: spamassassinConfig.learning requires a ReadWriteMany volume")
+	}
+	if p := s.Spec.Postmaster; p.ExistingSecret != "" && p.RotationInterval != nil {
+		return fmt.Errorf("postmaster: rotationInterval cannot be used with existingSecret")
+	}
+	if s.Spec.Postmaster.Address != "" && s.Spec.Config.PostmasterAddress != "" {
+		return fmt.Errorf("postmaster: address cannot be used with the deprecated config.postmasterAddress")
+	}
+	if e := s.Spec.Monitoring.Exporter; !e.Enabled && (e.ServiceMonitor.Enabled || e.PrometheusRule.Enabled) {
+		return fmt.Errorf("monitoring: exporter.serviceMonitor and exporter.prometheusRule require the exporter to be enabled")
+	}
+	if s.Spec.Relay != nil {
+		if err := s.Spec.Relay.Validate(); err != nil {
+			return fmt.Errorf("relay: %w", err)
+		}
+	}
+	return nil
+}
+
+// RspamdMinImageVersion is the first docker-mailserver version supporting the Rspamd configuration used by the operator
+const RspamdMinImageVersion = "13.0.0"
+
+// imageAtLeast reports whether the image tag is the given version or a later one.
+// The images pinned by digest or with a tag which is not a version, e.g. edge or latest, are accepted.
+func imageAtLeast(image, version string) bool {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return true
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return true
+	}
+	got, ok := parseVersion(image[i+1:])
+	if !ok {
+		return true
+	}
+	want, _ := parseVersion(version)
+	for i := range want {
+		if got[i] != want[i] {
+			return got[i] > want[i]
+		}
+	}
+	return true
+}
+
+// parseVersion parses the major, minor and patch numbers of a [v]major[.minor[.patch]][-suffix] version
+func parseVersion(v string) (version [3]int, ok bool) {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) > len(version) {
+		return version, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return version, false
+		}
+		version[i] = n
+	}
+	return version, true
+}
+
+// Validate rejects the mutually exclusive features
+func (f Features) Validate() error {
+	if f.Rspamd.Enabled {
+		if f.Amavis != nil && *f.Amavis {
+			return fmt.Errorf("rspamd cannot be enabled with amavis")
+		}
+		if f.Spamassassin != nil && *f.Spamassassin {
+			return fmt.Errorf("rspamd cannot be enabled with spamassassin")
+		}
+		if f.SpamassassinKam != nil && *f.SpamassassinKam {
+			return fmt.Errorf("rspamd cannot be enabled with spamassassinKam")
+		}
+	}
+	if f.SpamassassinConfig.Learning != nil && (f.Spamassassin == nil || !*f.Spamassassin) {
+		return fmt.Errorf("spamassassinConfig.learning requires spamassassin")
+	}
+	if f.ClamavServer != nil && (f.Clamav == nil || !*f.Clamav) {
+		return fmt.Errorf("clamavServer requires clamav")
+	}
+	if f.LDAP.Enabled {
+		filters := f.LDAP.Filters
+		if filters.User == "" {
+			filters.User = f.LDAP.UserFilter
+		}
+		if err := filters.Validate(); err != nil {
+			return fmt.Errorf("ldap: %w", err)
+		}
+		if ca := f.LDAP.TLS.CA; ca != nil && (ca.Secret == nil) == (ca.ConfigMap == nil) {
+			return fmt.Errorf("ldap: tls.ca requires exactly one of secret or configMap")
+		}
+	}
+	if err := f.OIDC.Validate(); err != nil {
+		return err
+	}
+	return f.Rspamd.Validate()
+}
+
+// Validate checks that the settings required by the introspection and the mail clients are set
+func (c OIDCConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.ClientID == "" {
+		return fmt.Errorf("oidc: clientID is required")
+	}
+	if c.ClientSecret == nil {
+		return fmt.Errorf("oidc: clientSecret is required")
+	}
+	for _, v := range []struct {
+		name     string
+		value    string
+		optional bool
+	}{
+		{name: "issuer", value: c.Issuer},
+		{name: "introspectionURL", value: c.IntrospectionURL},
+		{name: "authorizationURL", value: c.AuthorizationURL, optional: true},
+		{name: "tokenURL", value: c.TokenURL, optional: true},
+	} {
+		if v.optional && v.value == "" {
+			continue
+		}
+		u, err := url.Parse(v.value)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("oidc: %s must be an absolute http or https URL", v.name)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the filters overrides contain the placeholders they are queried with
+func (f LDAPFilters) Validate() error {
+	for _, v := range []struct {
+		name         string
+		filter       string
+		placeholders []string
+	}{
+		{name: "user", filter: f.User, placeholders: []string{"%s"}},
+		{name: "group", filter: f.Group, placeholders: []string{"%s"}},
+		{name: "alias", filter: f.Alias, placeholders: []string{"%s"}},
+		{name: "domain", filter: f.Domain, placeholders: []string{"%s"}},
+		{name: "dovecotUser", filter: f.DovecotUser, placeholders: []string{"%u"}},
+		{name: "dovecotPass", filter: f.DovecotPass, placeholders: []string{"%u"}},
+		{name: "sasl", filter: f.SASL, placeholders: []string{"%u", "%U"}},
+	} {
+		if v.filter == "" {
+			continue
+		}
+		if !strings.HasPrefix(v.filter, "(") || !strings.HasSuffix(v.filter, ")") {
+			return fmt.Errorf("filters.%s must be enclosed in parentheses", v.name)
+		}
+		var ok bool
+		for _, p := range v.placeholders {
+			ok = ok || strings.Contains(v.filter, p)
+		}
+		if !ok {
+			return fmt.Errorf("filters.%s must contain the %s placeholder", v.name, strings.Join(v.placeholders, " or "))
+		}
+	}
+	return nil
+}
+
+// Validate checks the Rspamd references consistency
+func (c RspamdConfig) Validate() error {
+	if c.Redis.PasswordSecret != "" && c.Redis.External == "" {
+		return fmt.Errorf("rspamd: redis.passwordSecret requires redis.external")
+	}
+	if c.WebUI.Enabled && c.WebUI.PasswordSecret == "" {
+		return fmt.Errorf("rspamd: webUI.passwordSecret is required when the web interface is enabled")
+	}
+	return nil
+}
+
+// Validate checks the allow and deny lists
+func (c SpamassassinConfig) Validate() error {
+	for _, list := range [][]string{c.Allow, c.Deny} {
+		for _, v := range list {
+			if v == "" || strings.ContainsAny(v, " \t\r\n") {
+				return fmt.Errorf("invalid sender %q", v)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks that the sender domains are unique
+func (c *RelayConfig) Validate() error {
+	domains := make(map[string]struct{})
+	for _, v := range c.Domains {
+		if _, ok := domains[v.Domain]; ok {
+			return fmt.Errorf("duplicate domain %s", v.Domain)
+		}
+		domains[v.Domain] = struct{}{}
+	}
+	return nil
+}
+
+// Validate checks the config consistency
+func (c Config) Validate() error {
+	if c.MoveSpamToJunk != nil && *c.MoveSpamToJunk && c.SpamassassinSpamToInbox != nil && !*c.SpamassassinSpamToInbox {
+		return fmt.Errorf("moveSpamToJunk requires spamassassinSpamToInbox")
+	}
+	tag, err := c.SATag.Float(2.0)
+	if err != nil {
+		return fmt.Errorf("saTag: %w", err)
+	}
+	tag2, err := c.SATag2.Float(6.31)
+	if err != nil {
+		return fmt.Errorf("saTag2: %w", err)
+	}
+	kill, err := c.SAKill.Float(6.31)
+	if err != nil {
+		return fmt.Errorf("saKill: %w", err)
+	}
+	if tag > tag2 {
+		return fmt.Errorf("saTag (%v) must be lower than or equal to saTag2 (%v)", tag, tag2)
+	}
+	if tag2 > kill {
+		return fmt.Errorf("saTag2 (%v) must be lower than or equal to saKill (%v)", tag2, kill)
+	}
+	return nil
+}
+
+// Float returns the score value or the default if unset
+func (s *SpamScore) Float(def float64) (float64, error) {
+	if s == nil {
+		return def, nil
+	}
+	return strconv.ParseFloat(string(*s), 64)
+}
@@ -0,0 +1,298 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"testing"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func score(v string) *SpamScore {
+	s := SpamScore(v)
+	return &s
+}
+
+func TestLDAPFiltersValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters LDAPFilters
+		err     bool
+	}{
+		{
+			name: "empty filters use the preset",
+		},
+		{
+			name: "valid overrides",
+			filters: LDAPFilters{
+				User:        "(&(objectClass=person)(mail=%s))",
+				Group:       "(&(objectClass=group)(mail=%s))",
+				Alias:       "(&(objectClass=person)(otherMailbox=%s))",
+				Domain:      "(&(objectClass=domain)(dc=%s))",
+				DovecotUser: "(&(objectClass=person)(uid=%u))",
+				DovecotPass: "(&(objectClass=person)(uid=%u))",
+				SASL:        "(&(objectClass=person)(uid=%U))",
+			},
+		},
+		{
+			name:    "missing parentheses",
+			filters: LDAPFilters{User: "mail=%s"},
+			err:     true,
+		},
+		{
+			name:    "missing user placeholder",
+			filters: LDAPFilters{User: "(mail=*)"},
+			err:     true,
+		},
+		{
+			name:    "postfix placeholder in dovecot filter",
+			filters: LDAPFilters{DovecotUser: "(uid=%s)"},
+			err:     true,
+		},
+		{
+			name:    "sasl accepts the user placeholder",
+			filters: LDAPFilters{SASL: "(uid=%u)"},
+		},
+		{
+			name:    "missing sasl placeholder",
+			filters: LDAPFilters{SASL: "(uid=*)"},
+			err:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filters.Validate(); (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		err    bool
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name:   "ordered scores",
+			config: Config{SATag: score("1.5"), SATag2: score("5"), SAKill: score("10")},
+		},
+		{
+			name:   "equal scores",
+			config: Config{SATag: score("5"), SATag2: score("5"), SAKill: score("5")},
+		},
+		{
+			name:   "tag above tag2",
+			config: Config{SATag: score("7")},
+			err:    true,
+		},
+		{
+			name:   "tag2 above kill",
+			config: Config{SATag2: score("8"), SAKill: score("7")},
+			err:    true,
+		},
+		{
+			name:   "invalid score",
+			config: Config{SAKill: score("high")},
+			err:    true,
+		},
+		{
+			name:   "move spam to junk with spam to inbox",
+			config: Config{MoveSpamToJunk: ptr(true), SpamassassinSpamToInbox: ptr(true)},
+		},
+		{
+			name:   "move spam to junk without spam to inbox",
+			config: Config{MoveSpamToJunk: ptr(true), SpamassassinSpamToInbox: ptr(false)},
+			err:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestFeaturesValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		features Features
+		err      bool
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name:     "rspamd",
+			features: Features{Rspamd: RspamdConfig{Enabled: true}, Amavis: ptr(false), Spamassassin: ptr(false)},
+		},
+		{
+			name:     "rspamd with amavis",
+			features: Features{Rspamd: RspamdConfig{Enabled: true}, Amavis: ptr(true)},
+			err:      true,
+		},
+		{
+			name:     "rspamd with spamassassin",
+			features: Features{Rspamd: RspamdConfig{Enabled: true}, Spamassassin: ptr(true)},
+			err:      true,
+		},
+		{
+			name:     "rspamd with spamassassin kam",
+			features: Features{Rspamd: RspamdConfig{Enabled: true}, SpamassassinKam: ptr(true)},
+			err:      true,
+		},
+		{
+			name:     "rspamd redis password without external redis",
+			features: Features{Rspamd: RspamdConfig{Enabled: true, Redis: RspamdRedisConfig{PasswordSecret: "redis"}}},
+			err:      true,
+		},
+		{
+			name:     "rspamd web interface without password",
+			features: Features{Rspamd: RspamdConfig{Enabled: true, WebUI: RspamdWebUIConfig{Enabled: true}}},
+			err:      true,
+		},
+		{
+			name:     "learning without spamassassin",
+			features: Features{SpamassassinConfig: SpamassassinConfig{Learning: &SpamLearningConfig{}}},
+			err:      true,
+		},
+		{
+			name:     "learning with spamassassin",
+			features: Features{Spamassassin: ptr(true), SpamassassinConfig: SpamassassinConfig{Learning: &SpamLearningConfig{}}},
+		},
+		{
+			name:     "clamav server without clamav",
+			features: Features{ClamavServer: &ClamavServerConfig{}},
+			err:      true,
+		},
+		{
+			name:     "clamav server with clamav",
+			features: Features{Clamav: ptr(true), ClamavServer: &ClamavServerConfig{}},
+		},
+		{
+			name:     "ldap user filter without placeholder",
+			features: Features{LDAP: LDAPConfig{Enabled: true, UserFilter: "(mail=*)"}},
+			err:      true,
+		},
+		{
+			name:     "ldap filters override the deprecated user filter",
+			features: Features{LDAP: LDAPConfig{Enabled: true, UserFilter: "(mail=*)", Filters: LDAPFilters{User: "(mail=%s)"}}},
+		},
+		{
+			name: "ldap ca with both sources",
+			features: Features{LDAP: LDAPConfig{Enabled: true, TLS: LDAPTLSConfig{CA: &LDAPCABundle{
+				Secret:    &SecretKeyRef{Name: "ca", Key: "ca.crt"},
+				ConfigMap: &ConfigMapKeyRef{Name: "ca", Key: "ca.crt"},
+			}}}},
+			err: true,
+		},
+		{
+			name:     "ldap ca without source",
+			features: Features{LDAP: LDAPConfig{Enabled: true, TLS: LDAPTLSConfig{CA: &LDAPCABundle{}}}},
+			err:      true,
+		},
+		{
+			name:     "oidc without client",
+			features: Features{OIDC: OIDCConfig{Enabled: true, Issuer: "https://sso.example.org", IntrospectionURL: "https://sso.example.org/introspect"}},
+			err:      true,
+		},
+		{
+			name: "oidc relative issuer",
+			features: Features{OIDC: OIDCConfig{
+				Enabled:          true,
+				ClientID:         "mail",
+				ClientSecret:     &SecretKeyRef{Name: "oidc", Key: "secret"},
+				Issuer:           "sso.example.org",
+				IntrospectionURL: "https://sso.example.org/introspect",
+			}},
+			err: true,
+		},
+		{
+			name: "oidc",
+			features: Features{OIDC: OIDCConfig{
+				Enabled:          true,
+				ClientID:         "mail",
+				ClientSecret:     &SecretKeyRef{Name: "oidc", Key: "secret"},
+				Issuer:           "https://sso.example.org",
+				IntrospectionURL: "https://sso.example.org/introspect",
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.features.Validate(); (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestRspamdImageValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		image string
+		err   bool
+	}{
+		{
+			name:  "default image",
+			image: "docker.io/mailserver/docker-mailserver:9.1.0",
+			err:   true,
+		},
+		{
+			name:  "older minor version",
+			image: "docker.io/mailserver/docker-mailserver:12.1",
+			err:   true,
+		},
+		{
+			name:  "minimum version",
+			image: "docker.io/mailserver/docker-mailserver:13.0.0",
+		},
+		{
+			name:  "later version",
+			image: "mailserver/docker-mailserver:v14.0.0-rc1",
+		},
+		{
+			name:  "registry port without tag",
+			image: "registry.example.org:5000/docker-mailserver",
+		},
+		{
+			name:  "edge tag",
+			image: "docker.io/mailserver/docker-mailserver:edge",
+		},
+		{
+			name:  "digest",
+			image: "docker.io/mailserver/docker-mailserver@sha256:0123456789abcdef",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &MailServer{Spec: MailServerSpec{
+				Image:    tt.image,
+				Features: Features{Rspamd: RspamdConfig{Enabled: true}},
+			}}
+			if err := s.Validate(); (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
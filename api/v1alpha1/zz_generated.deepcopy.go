@@ -21,6 +21,7 @@ package v1alpha1
 
 import (
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -62,6 +63,200 @@ func (in *AutoConfigDeployment) DeepCopy() *AutoConfigDeployment {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClamavDeployment) DeepCopyInto(out *ClamavDeployment) {
+	*out = *in
+	in.ComponentDeployment.DeepCopyInto(&out.ComponentDeployment)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClamavDeployment.
+func (in *ClamavDeployment) DeepCopy() *ClamavDeployment {
+	if in == nil {
+		return nil
+	}
+	out := new(ClamavDeployment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClamavServerConfig) DeepCopyInto(out *ClamavServerConfig) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	in.Deployment.DeepCopyInto(&out.Deployment)
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(VolumeConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClamavServerConfig.
+func (in *ClamavServerConfig) DeepCopy() *ClamavServerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ClamavServerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComponentDeployment) DeepCopyInto(out *ComponentDeployment) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]v1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentDeployment.
+func (in *ComponentDeployment) DeepCopy() *ComponentDeployment {
+	if in == nil {
+		return nil
+	}
+	out := new(ComponentDeployment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Config) DeepCopyInto(out *Config) {
+	*out = *in
+	if in.EnableUpdateCheck != nil {
+		in, out := &in.EnableUpdateCheck, &out.EnableUpdateCheck
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AmavisLogLevel != nil {
+		in, out := &in.AmavisLogLevel, &out.AmavisLogLevel
+		*out = new(int)
+		**out = **in
+	}
+	if in.EnableDNSBL != nil {
+		in, out := &in.EnableDNSBL, &out.EnableDNSBL
+		*out = new(bool)
+		**out = **in
+	}
+	if in.VirusMailsDeleteDelay != nil {
+		in, out := &in.VirusMailsDeleteDelay, &out.VirusMailsDeleteDelay
+		*out = new(int)
+		**out = **in
+	}
+	if in.PostfixMailboxSizeLimit != nil {
+		in, out := &in.PostfixMailboxSizeLimit, &out.PostfixMailboxSizeLimit
+		*out = new(int64)
+		**out = **in
+	}
+	if in.PostfixMessageSizeLimit != nil {
+		in, out := &in.PostfixMessageSizeLimit, &out.PostfixMessageSizeLimit
+		*out = new(int64)
+		**out = **in
+	}
+	if in.SpamassassinSpamToInbox != nil {
+		in, out := &in.SpamassassinSpamToInbox, &out.SpamassassinSpamToInbox
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MoveSpamToJunk != nil {
+		in, out := &in.MoveSpamToJunk, &out.MoveSpamToJunk
+		*out = new(bool)
+		**out = **in
+	}
+	if in.SATag != nil {
+		in, out := &in.SATag, &out.SATag
+		*out = new(SpamScore)
+		**out = **in
+	}
+	if in.SATag2 != nil {
+		in, out := &in.SATag2, &out.SATag2
+		*out = new(SpamScore)
+		**out = **in
+	}
+	if in.SAKill != nil {
+		in, out := &in.SAKill, &out.SAKill
+		*out = new(SpamScore)
+		**out = **in
+	}
+	if in.SASpamSubject != nil {
+		in, out := &in.SASpamSubject, &out.SASpamSubject
+		*out = new(string)
+		**out = **in
+	}
+	if in.FetchmailPoll != nil {
+		in, out := &in.FetchmailPoll, &out.FetchmailPoll
+		*out = new(int)
+		**out = **in
+	}
+	if in.PostgreyDelay != nil {
+		in, out := &in.PostgreyDelay, &out.PostgreyDelay
+		*out = new(int)
+		**out = **in
+	}
+	if in.PostgreyMaxAge != nil {
+		in, out := &in.PostgreyMaxAge, &out.PostgreyMaxAge
+		*out = new(int)
+		**out = **in
+	}
+	if in.PostgreyAutoWhitelistClients != nil {
+		in, out := &in.PostgreyAutoWhitelistClients, &out.PostgreyAutoWhitelistClients
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Config.
+func (in *Config) DeepCopy() *Config {
+	if in == nil {
+		return nil
+	}
+	out := new(Config)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapKeyRef) DeepCopyInto(out *ConfigMapKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKeyRef.
+func (in *ConfigMapKeyRef) DeepCopy() *ConfigMapKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeploymentConfig) DeepCopyInto(out *DeploymentConfig) {
 	*out = *in
@@ -133,6 +328,12 @@ func (in *DeploymentConfig) DeepCopyInto(out *DeploymentConfig) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.TerminationGracePeriodSeconds != nil {
+		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	in.Probes.DeepCopyInto(&out.Probes)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeploymentConfig.
@@ -145,6 +346,41 @@ func (in *DeploymentConfig) DeepCopy() *DeploymentConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExporterConfig) DeepCopyInto(out *ExporterConfig) {
+	*out = *in
+	in.Postfix.DeepCopyInto(&out.Postfix)
+	in.Dovecot.DeepCopyInto(&out.Dovecot)
+	in.ServiceMonitor.DeepCopyInto(&out.ServiceMonitor)
+	in.PrometheusRule.DeepCopyInto(&out.PrometheusRule)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExporterConfig.
+func (in *ExporterConfig) DeepCopy() *ExporterConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ExporterConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExporterContainer) DeepCopyInto(out *ExporterContainer) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExporterContainer.
+func (in *ExporterContainer) DeepCopy() *ExporterContainer {
+	if in == nil {
+		return nil
+	}
+	out := new(ExporterContainer)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Features) DeepCopyInto(out *Features) {
 	*out = *in
@@ -163,6 +399,11 @@ func (in *Features) DeepCopyInto(out *Features) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.ClamavServer != nil {
+		in, out := &in.ClamavServer, &out.ClamavServer
+		*out = new(ClamavServerConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Amavis != nil {
 		in, out := &in.Amavis, &out.Amavis
 		*out = new(bool)
@@ -193,12 +434,16 @@ func (in *Features) DeepCopyInto(out *Features) {
 		*out = new(bool)
 		**out = **in
 	}
+	in.SpamassassinConfig.DeepCopyInto(&out.SpamassassinConfig)
 	if in.Postgrey != nil {
 		in, out := &in.Postgrey, &out.Postgrey
 		*out = new(bool)
 		**out = **in
 	}
+	in.SRS.DeepCopyInto(&out.SRS)
+	in.Rspamd.DeepCopyInto(&out.Rspamd)
 	in.LDAP.DeepCopyInto(&out.LDAP)
+	in.OIDC.DeepCopyInto(&out.OIDC)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Features.
@@ -212,49 +457,7 @@ func (in *Features) DeepCopy() *Features {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IngressConfig) DeepCopyInto(out *IngressConfig) {
-	*out = *in
-	if in.Annotations != nil {
-		in, out := &in.Annotations, &out.Annotations
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngressConfig.
-func (in *IngressConfig) DeepCopy() *IngressConfig {
-	if in == nil {
-		return nil
-	}
-	out := new(IngressConfig)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LDAPConfig) DeepCopyInto(out *LDAPConfig) {
-	*out = *in
-	if in.Nameserver != nil {
-		in, out := &in.Nameserver, &out.Nameserver
-		*out = new(IPv4)
-		**out = **in
-	}
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPConfig.
-func (in *LDAPConfig) DeepCopy() *LDAPConfig {
-	if in == nil {
-		return nil
-	}
-	out := new(LDAPConfig)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MailServer) DeepCopyInto(out *MailServer) {
+func (in *FetchmailSource) DeepCopyInto(out *FetchmailSource) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -262,18 +465,18 @@ func (in *MailServer) DeepCopyInto(out *MailServer) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailServer.
-func (in *MailServer) DeepCopy() *MailServer {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FetchmailSource.
+func (in *FetchmailSource) DeepCopy() *FetchmailSource {
 	if in == nil {
 		return nil
 	}
-	out := new(MailServer)
+	out := new(FetchmailSource)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MailServer) DeepCopyObject() runtime.Object {
+func (in *FetchmailSource) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -281,31 +484,31 @@ func (in *MailServer) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MailServerList) DeepCopyInto(out *MailServerList) {
+func (in *FetchmailSourceList) DeepCopyInto(out *FetchmailSourceList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]MailServer, len(*in))
+		*out = make([]FetchmailSource, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailServerList.
-func (in *MailServerList) DeepCopy() *MailServerList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FetchmailSourceList.
+func (in *FetchmailSourceList) DeepCopy() *FetchmailSourceList {
 	if in == nil {
 		return nil
 	}
-	out := new(MailServerList)
+	out := new(FetchmailSourceList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MailServerList) DeepCopyObject() runtime.Object {
+func (in *FetchmailSourceList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -313,71 +516,818 @@ func (in *MailServerList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MailServerSpec) DeepCopyInto(out *MailServerSpec) {
+func (in *FetchmailSourceSpec) DeepCopyInto(out *FetchmailSourceSpec) {
 	*out = *in
-	in.DeploymentConfig.DeepCopyInto(&out.DeploymentConfig)
-	in.AutoConfig.DeepCopyInto(&out.AutoConfig)
-	if in.LoadBalancerClass != nil {
-		in, out := &in.LoadBalancerClass, &out.LoadBalancerClass
-		*out = new(string)
-		**out = **in
-	}
-	if in.LoadBalancerIP != nil {
-		in, out := &in.LoadBalancerIP, &out.LoadBalancerIP
-		*out = new(IPv4)
-		**out = **in
-	}
-	if in.OverrideIP != nil {
-		in, out := &in.OverrideIP, &out.OverrideIP
-		*out = new(IPv4)
-		**out = **in
-	}
-	if in.Replicas != nil {
-		in, out := &in.Replicas, &out.Replicas
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
 		*out = new(int32)
 		**out = **in
 	}
-	out.IssuerRef = in.IssuerRef
-	in.Features.DeepCopyInto(&out.Features)
-	out.Volume = in.Volume
-	if in.Traefik != nil {
-		in, out := &in.Traefik, &out.Traefik
-		*out = new(TraefikConfig)
+	if in.SSL != nil {
+		in, out := &in.SSL, &out.SSL
+		*out = new(bool)
 		**out = **in
 	}
+	if in.Folders != nil {
+		in, out := &in.Folders, &out.Folders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailServerSpec.
-func (in *MailServerSpec) DeepCopy() *MailServerSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FetchmailSourceSpec.
+func (in *FetchmailSourceSpec) DeepCopy() *FetchmailSourceSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(MailServerSpec)
+	out := new(FetchmailSourceSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MailServerStatus) DeepCopyInto(out *MailServerStatus) {
+func (in *FetchmailSourceStatus) DeepCopyInto(out *FetchmailSourceStatus) {
 	*out = *in
-	if in.Traefik != nil {
-		in, out := &in.Traefik, &out.Traefik
-		*out = new(bool)
-		**out = **in
-	}
-	if in.AutoConfig != nil {
-		in, out := &in.AutoConfig, &out.AutoConfig
-		*out = new(bool)
-		**out = **in
+	if in.LastPollTime != nil {
+		in, out := &in.LastPollTime, &out.LastPollTime
+		*out = (*in).DeepCopy()
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailServerStatus.
-func (in *MailServerStatus) DeepCopy() *MailServerStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FetchmailSourceStatus.
+func (in *FetchmailSourceStatus) DeepCopy() *FetchmailSourceStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(MailServerStatus)
+	out := new(FetchmailSourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IngressConfig) DeepCopyInto(out *IngressConfig) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngressConfig.
+func (in *IngressConfig) DeepCopy() *IngressConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(IngressConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPCABundle) DeepCopyInto(out *LDAPCABundle) {
+	*out = *in
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(ConfigMapKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPCABundle.
+func (in *LDAPCABundle) DeepCopy() *LDAPCABundle {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPCABundle)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPConfig) DeepCopyInto(out *LDAPConfig) {
+	*out = *in
+	if in.Nameserver != nil {
+		in, out := &in.Nameserver, &out.Nameserver
+		*out = new(IPv4)
+		**out = **in
+	}
+	in.TLS.DeepCopyInto(&out.TLS)
+	out.Filters = in.Filters
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPConfig.
+func (in *LDAPConfig) DeepCopy() *LDAPConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPFilters) DeepCopyInto(out *LDAPFilters) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPFilters.
+func (in *LDAPFilters) DeepCopy() *LDAPFilters {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPFilters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPStatus) DeepCopyInto(out *LDAPStatus) {
+	*out = *in
+	if in.LastCheckTime != nil {
+		in, out := &in.LastCheckTime, &out.LastCheckTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPStatus.
+func (in *LDAPStatus) DeepCopy() *LDAPStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LDAPTLSConfig) DeepCopyInto(out *LDAPTLSConfig) {
+	*out = *in
+	if in.CA != nil {
+		in, out := &in.CA, &out.CA
+		*out = new(LDAPCABundle)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LDAPTLSConfig.
+func (in *LDAPTLSConfig) DeepCopy() *LDAPTLSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LDAPTLSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailServer) DeepCopyInto(out *MailServer) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailServer.
+func (in *MailServer) DeepCopy() *MailServer {
+	if in == nil {
+		return nil
+	}
+	out := new(MailServer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MailServer) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailServerList) DeepCopyInto(out *MailServerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MailServer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailServerList.
+func (in *MailServerList) DeepCopy() *MailServerList {
+	if in == nil {
+		return nil
+	}
+	out := new(MailServerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MailServerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailServerSpec) DeepCopyInto(out *MailServerSpec) {
+	*out = *in
+	in.DeploymentConfig.DeepCopyInto(&out.DeploymentConfig)
+	in.AutoConfig.DeepCopyInto(&out.AutoConfig)
+	if in.LoadBalancerClass != nil {
+		in, out := &in.LoadBalancerClass, &out.LoadBalancerClass
+		*out = new(string)
+		**out = **in
+	}
+	if in.LoadBalancerIP != nil {
+		in, out := &in.LoadBalancerIP, &out.LoadBalancerIP
+		*out = new(IPv4)
+		**out = **in
+	}
+	if in.OverrideIP != nil {
+		in, out := &in.OverrideIP, &out.OverrideIP
+		*out = new(IPv4)
+		**out = **in
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	out.IssuerRef = in.IssuerRef
+	in.Features.DeepCopyInto(&out.Features)
+	in.Config.DeepCopyInto(&out.Config)
+	in.Postmaster.DeepCopyInto(&out.Postmaster)
+	if in.Relay != nil {
+		in, out := &in.Relay, &out.Relay
+		*out = new(RelayConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Overrides.DeepCopyInto(&out.Overrides)
+	out.Volume = in.Volume
+	if in.Traefik != nil {
+		in, out := &in.Traefik, &out.Traefik
+		*out = new(TraefikConfig)
+		**out = **in
+	}
+	in.Monitoring.DeepCopyInto(&out.Monitoring)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailServerSpec.
+func (in *MailServerSpec) DeepCopy() *MailServerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MailServerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MailServerStatus) DeepCopyInto(out *MailServerStatus) {
+	*out = *in
+	if in.Traefik != nil {
+		in, out := &in.Traefik, &out.Traefik
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AutoConfig != nil {
+		in, out := &in.AutoConfig, &out.AutoConfig
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ModeTransitionTime != nil {
+		in, out := &in.ModeTransitionTime, &out.ModeTransitionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Restart != nil {
+		in, out := &in.Restart, &out.Restart
+		*out = new(RestartStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Postmaster != nil {
+		in, out := &in.Postmaster, &out.Postmaster
+		*out = new(PostmasterStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SpamLearning != nil {
+		in, out := &in.SpamLearning, &out.SpamLearning
+		*out = new(SpamLearningStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LDAP != nil {
+		in, out := &in.LDAP, &out.LDAP
+		*out = new(LDAPStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MailServerStatus.
+func (in *MailServerStatus) DeepCopy() *MailServerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MailServerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringConfig) DeepCopyInto(out *MonitoringConfig) {
+	*out = *in
+	in.Exporter.DeepCopyInto(&out.Exporter)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringConfig.
+func (in *MonitoringConfig) DeepCopy() *MonitoringConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDCConfig) DeepCopyInto(out *OIDCConfig) {
+	*out = *in
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClientSecret != nil {
+		in, out := &in.ClientSecret, &out.ClientSecret
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDCConfig.
+func (in *OIDCConfig) DeepCopy() *OIDCConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDCConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Overrides) DeepCopyInto(out *Overrides) {
+	*out = *in
+	if in.PostfixMain != nil {
+		in, out := &in.PostfixMain, &out.PostfixMain
+		*out = new(ConfigMapKeyRef)
+		**out = **in
+	}
+	if in.PostfixMaster != nil {
+		in, out := &in.PostfixMaster, &out.PostfixMaster
+		*out = new(ConfigMapKeyRef)
+		**out = **in
+	}
+	if in.Dovecot != nil {
+		in, out := &in.Dovecot, &out.Dovecot
+		*out = new(ConfigMapKeyRef)
+		**out = **in
+	}
+	if in.PolicydSPF != nil {
+		in, out := &in.PolicydSPF, &out.PolicydSPF
+		*out = new(ConfigMapKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Overrides.
+func (in *Overrides) DeepCopy() *Overrides {
+	if in == nil {
+		return nil
+	}
+	out := new(Overrides)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostmasterConfig) DeepCopyInto(out *PostmasterConfig) {
+	*out = *in
+	if in.RotationInterval != nil {
+		in, out := &in.RotationInterval, &out.RotationInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostmasterConfig.
+func (in *PostmasterConfig) DeepCopy() *PostmasterConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PostmasterConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostmasterStatus) DeepCopyInto(out *PostmasterStatus) {
+	*out = *in
+	if in.LastRotationTime != nil {
+		in, out := &in.LastRotationTime, &out.LastRotationTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostmasterStatus.
+func (in *PostmasterStatus) DeepCopy() *PostmasterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostmasterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeConfig) DeepCopyInto(out *ProbeConfig) {
+	*out = *in
+	if in.InitialDelaySeconds != nil {
+		in, out := &in.InitialDelaySeconds, &out.InitialDelaySeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PeriodSeconds != nil {
+		in, out := &in.PeriodSeconds, &out.PeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailureThreshold != nil {
+		in, out := &in.FailureThreshold, &out.FailureThreshold
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeConfig.
+func (in *ProbeConfig) DeepCopy() *ProbeConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbesConfig) DeepCopyInto(out *ProbesConfig) {
+	*out = *in
+	in.Startup.DeepCopyInto(&out.Startup)
+	in.Liveness.DeepCopyInto(&out.Liveness)
+	in.Readiness.DeepCopyInto(&out.Readiness)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbesConfig.
+func (in *ProbesConfig) DeepCopy() *ProbesConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbesConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusRuleConfig) DeepCopyInto(out *PrometheusRuleConfig) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusRuleConfig.
+func (in *PrometheusRuleConfig) DeepCopy() *PrometheusRuleConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PrometheusRuleConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RedisDeployment) DeepCopyInto(out *RedisDeployment) {
+	*out = *in
+	in.ComponentDeployment.DeepCopyInto(&out.ComponentDeployment)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RedisDeployment.
+func (in *RedisDeployment) DeepCopy() *RedisDeployment {
+	if in == nil {
+		return nil
+	}
+	out := new(RedisDeployment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RelayConfig) DeepCopyInto(out *RelayConfig) {
+	*out = *in
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Domains != nil {
+		in, out := &in.Domains, &out.Domains
+		*out = make([]RelayDomain, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RelayConfig.
+func (in *RelayConfig) DeepCopy() *RelayConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RelayConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RelayDomain) DeepCopyInto(out *RelayDomain) {
+	*out = *in
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RelayDomain.
+func (in *RelayDomain) DeepCopy() *RelayDomain {
+	if in == nil {
+		return nil
+	}
+	out := new(RelayDomain)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestartStatus) DeepCopyInto(out *RestartStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestartStatus.
+func (in *RestartStatus) DeepCopy() *RestartStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RspamdConfig) DeepCopyInto(out *RspamdConfig) {
+	*out = *in
+	in.Redis.DeepCopyInto(&out.Redis)
+	if in.DKIMSigning != nil {
+		in, out := &in.DKIMSigning, &out.DKIMSigning
+		*out = new(bool)
+		**out = **in
+	}
+	in.WebUI.DeepCopyInto(&out.WebUI)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RspamdConfig.
+func (in *RspamdConfig) DeepCopy() *RspamdConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RspamdConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RspamdRedisConfig) DeepCopyInto(out *RspamdRedisConfig) {
+	*out = *in
+	in.Deployment.DeepCopyInto(&out.Deployment)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RspamdRedisConfig.
+func (in *RspamdRedisConfig) DeepCopy() *RspamdRedisConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RspamdRedisConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RspamdWebUIConfig) DeepCopyInto(out *RspamdWebUIConfig) {
+	*out = *in
+	in.Ingress.DeepCopyInto(&out.Ingress)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RspamdWebUIConfig.
+func (in *RspamdWebUIConfig) DeepCopy() *RspamdWebUIConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RspamdWebUIConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SRSConfig) DeepCopyInto(out *SRSConfig) {
+	*out = *in
+	if in.ExcludeDomains != nil {
+		in, out := &in.ExcludeDomains, &out.ExcludeDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SenderClasses != nil {
+		in, out := &in.SenderClasses, &out.SenderClasses
+		*out = make([]SRSSenderClass, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SRSConfig.
+func (in *SRSConfig) DeepCopy() *SRSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SRSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyRef) DeepCopyInto(out *SecretKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyRef.
+func (in *SecretKeyRef) DeepCopy() *SecretKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceMonitorConfig) DeepCopyInto(out *ServiceMonitorConfig) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceMonitorConfig.
+func (in *ServiceMonitorConfig) DeepCopy() *ServiceMonitorConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceMonitorConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpamLearningConfig) DeepCopyInto(out *SpamLearningConfig) {
+	*out = *in
+	if in.SpamFolders != nil {
+		in, out := &in.SpamFolders, &out.SpamFolders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.HamFolders != nil {
+		in, out := &in.HamFolders, &out.HamFolders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxAgeDays != nil {
+		in, out := &in.MaxAgeDays, &out.MaxAgeDays
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpamLearningConfig.
+func (in *SpamLearningConfig) DeepCopy() *SpamLearningConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SpamLearningConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpamLearningStatus) DeepCopyInto(out *SpamLearningStatus) {
+	*out = *in
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSuccessfulTime != nil {
+		in, out := &in.LastSuccessfulTime, &out.LastSuccessfulTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpamLearningStatus.
+func (in *SpamLearningStatus) DeepCopy() *SpamLearningStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SpamLearningStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpamassassinConfig) DeepCopyInto(out *SpamassassinConfig) {
+	*out = *in
+	if in.Allow != nil {
+		in, out := &in.Allow, &out.Allow
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Deny != nil {
+		in, out := &in.Deny, &out.Deny
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Learning != nil {
+		in, out := &in.Learning, &out.Learning
+		*out = new(SpamLearningConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpamassassinConfig.
+func (in *SpamassassinConfig) DeepCopy() *SpamassassinConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SpamassassinConfig)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -15,14 +15,21 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	kubectl_mailserver "go.linka.cloud/kube-mailserver/pkg/kubectl-mailserver"
+	"go.linka.cloud/kube-mailserver/pkg/podexec"
 )
 
 func main() {
 	if err := kubectl_mailserver.RootCmd.Execute(); err != nil {
+		// the setup command already printed its error, only propagate its exit code
+		var exitErr *podexec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		fmt.Println(err)
 		os.Exit(1)
 	}
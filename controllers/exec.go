@@ -15,87 +15,47 @@
 package controllers
 
 import (
-	"bytes"
 	"context"
-	"fmt"
 	"io"
-	"net/http"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	v1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/tools/remotecommand"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"go.linka.cloud/kube-mailserver/pkg/podexec"
 )
 
-func (r *MailServerReconciler) execDeployOut(ctx context.Context, deploy *appsv1.Deployment, command string) (string, bool, error) {
+// execTimeout bounds the commands run in the mail server pods by the reconcilers
+const execTimeout = time.Minute
+
+// execDeployOut runs the command in a ready pod of the deployment and returns its output.
+// It returns false if no pod is ready.
+func (r *MailServerReconciler) execDeployOut(ctx context.Context, deploy *appsv1.Deployment, command ...string) (string, bool, error) {
+	return r.execDeployStdin(ctx, deploy, nil, command...)
+}
+
+// execDeployStdin runs the command with the given standard input in a ready pod of the deployment and returns its output.
+// It returns false if no pod is ready.
+func (r *MailServerReconciler) execDeployStdin(ctx context.Context, deploy *appsv1.Deployment, stdin io.Reader, command ...string) (string, bool, error) {
 	log := ctrl.LoggerFrom(ctx)
-	var pods corev1.PodList
-	if err := r.List(ctx, &pods, client.InNamespace(deploy.Namespace), client.MatchingLabels(deploy.Spec.Template.Labels)); err != nil {
+	pod, err := podexec.ReadyPod(ctx, r, deploy.Namespace, deploy.Spec.Template.Labels)
+	if err != nil {
 		return "", false, err
 	}
-	if len(pods.Items) == 0 {
+	if pod == nil {
 		log.V(5).Info("mail server pod not ready yet")
 		return "", false, nil
 	}
-
-	if pods.Items[0].Status.Phase != corev1.PodRunning {
-		log.V(5).Info("mail server pod not running yet")
-		return "", false, nil
-	}
-	out, err := r.execOut(client.ObjectKeyFromObject(&pods.Items[0]), command)
+	ctx, cancel := context.WithTimeout(ctx, execTimeout)
+	defer cancel()
+	out, err := podexec.OutputStdin(ctx, r.Exec, pod, stdin, command...)
 	return out, true, err
 }
 
-func (r *MailServerReconciler) execOut(key client.ObjectKey, command string) (string, error) {
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	if err := r.exec(key, command, nil, &stdout, &stderr); err != nil {
-		return "", fmt.Errorf("%v: %s", err, stderr.String())
-	}
-	return stdout.String(), nil
-}
-
-// exec execute command on specific pod and wait the command's output.
-func (r *MailServerReconciler) exec(key client.ObjectKey, command string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
-	cmd := []string{
-		"sh",
-		"-c",
-		command,
-	}
-
-	req := r.GoClient.CoreV1().RESTClient().
-		Post().
-		Resource("pods").
-		Namespace(key.Namespace).
-		Name(key.Name).
-		SubResource("exec")
-	option := &v1.PodExecOptions{
-		Command: cmd,
-		Stdin:   true,
-		Stdout:  true,
-		Stderr:  true,
-		TTY:     true,
-	}
-	if stdin == nil {
-		option.Stdin = false
-	}
-	req.VersionedParams(
-		option,
-		scheme.ParameterCodec,
-	)
-	exec, err := remotecommand.NewSPDYExecutor(r.RestConfig, http.MethodPost, req.URL())
-	if err != nil {
-		return err
-	}
-	if err = exec.Stream(remotecommand.StreamOptions{
-		Stdin:  stdin,
-		Stdout: stdout,
-		Stderr: stderr,
-	}); err != nil {
-		return err
-	}
-	return nil
+// execOut runs the command in the pod and returns its output
+func (r *MailServerReconciler) execOut(ctx context.Context, pod *corev1.Pod, command ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, execTimeout)
+	defer cancel()
+	return podexec.Output(ctx, r.Exec, pod, command...)
 }
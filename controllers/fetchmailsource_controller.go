@@ -0,0 +1,142 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+	"go.linka.cloud/kube-mailserver/pkg/podexec"
+	"go.linka.cloud/kube-mailserver/pkg/resources"
+)
+
+const (
+	// fetchmailDefaultPoll is the docker-mailserver default fetchmail poll interval
+	fetchmailDefaultPoll = 300 * time.Second
+	// fetchmailNotLoaded is the check exit code when the source is not yet in the mail server fetchmail configuration
+	fetchmailNotLoaded = 100
+	// fetchmailMinCheckInterval limits the additional logins of the connectivity probes to the remote mailbox
+	fetchmailMinCheckInterval = 15 * time.Minute
+)
+
+// FetchmailSourceReconciler reconciles a FetchmailSource object
+// The fetchmail.cf file is rendered by the MailServer reconciler, this one only probes the remote mailboxes
+// with fetchmail --check and reports the result, it does not observe the fetchmail daemon polls.
+type FetchmailSourceReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// Exec runs the fetchmail checks in the mail server pods
+	Exec podexec.Executor
+}
+
+// +kubebuilder:rbac:groups=mail.linka.cloud,resources=fetchmailsources,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=mail.linka.cloud,resources=fetchmailsources/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=mail.linka.cloud,resources=fetchmailsources/finalizers,verbs=update
+
+// Reconcile probes the remote mailbox from the mail server pod and reports the result in the status.
+func (r *FetchmailSourceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	var f mailv1alpha1.FetchmailSource
+	if err := r.Get(ctx, req.NamespacedName, &f); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "unable to fetch FetchmailSource")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	var s mailv1alpha1.MailServer
+	if err := r.Get(ctx, client.ObjectKey{Namespace: f.Namespace, Name: f.Spec.MailServer}, &s); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "unable to fetch MailServer")
+			return ctrl.Result{}, err
+		}
+		return r.updateStatus(ctx, &f, mailv1alpha1.FetchmailPollPending, "mail server not found", fetchmailDefaultPoll)
+	}
+	poll := fetchmailDefaultPoll
+	if s.Spec.Config.FetchmailPoll != nil {
+		poll = time.Duration(*s.Spec.Config.FetchmailPoll) * time.Second
+	}
+	// each probe is an additional login, they are not run at each daemon poll
+	if poll < fetchmailMinCheckInterval {
+		poll = fetchmailMinCheckInterval
+	}
+
+	pod, err := podexec.ReadyPod(ctx, r, s.Namespace, resources.Labels(&s, "server"))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if pod == nil {
+		return r.updateStatus(ctx, &f, mailv1alpha1.FetchmailPollPending, "mail server not ready", poll)
+	}
+
+	cmd := podexec.Shell(
+		`grep -qF "\"$1\"" /etc/fetchmailrc || exit $2; su -s /bin/sh fetchmail -c "fetchmail --check --nosyslog --pidfile /tmp/fetchmail-check.pid -f /etc/fetchmailrc '$1'"`,
+		resources.FetchmailLabel(&f),
+		strconv.Itoa(fetchmailNotLoaded),
+	)
+	execCtx, cancel := context.WithTimeout(ctx, execTimeout)
+	defer cancel()
+	out, err := podexec.Output(execCtx, r.Exec, pod, cmd...)
+	var exitErr *podexec.ExitError
+	switch {
+	case err == nil:
+		// fetchmail exits with 0 when there are messages to retrieve
+	case errors.As(err, &exitErr) && exitErr.Code == 1:
+		// fetchmail exits with 1 when there is no mail
+	case errors.As(err, &exitErr) && exitErr.Code == fetchmailNotLoaded:
+		return r.updateStatus(ctx, &f, mailv1alpha1.FetchmailPollPending, "waiting for the mail server to load the source", 10*time.Second)
+	case errors.As(err, &exitErr):
+		log.Info("fetchmail check failed", "exitCode", exitErr.Code)
+		return r.updateStatus(ctx, &f, mailv1alpha1.FetchmailPollFailed, strings.TrimSpace(out+"\n"+exitErr.Stderr), poll)
+	default:
+		log.Error(err, "unable to run fetchmail check")
+		return ctrl.Result{}, err
+	}
+	return r.updateStatus(ctx, &f, mailv1alpha1.FetchmailPollSuccess, "", poll)
+}
+
+func (r *FetchmailSourceReconciler) updateStatus(ctx context.Context, f *mailv1alpha1.FetchmailSource, result mailv1alpha1.FetchmailPollResult, message string, requeue time.Duration) (ctrl.Result, error) {
+	f.Status.LastPollResult = result
+	f.Status.Message = message
+	if result != mailv1alpha1.FetchmailPollPending {
+		f.Status.LastPollTime = &metav1.Time{Time: time.Now()}
+	}
+	if err := r.Status().Update(ctx, f); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "unable to update fetchmail source status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: requeue}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *FetchmailSourceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// the status updates must not trigger a new check, the sources are polled at the fetchmail poll interval
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&mailv1alpha1.FetchmailSource{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
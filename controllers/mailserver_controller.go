@@ -17,7 +17,12 @@ package controllers
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,21 +31,25 @@ import (
 	traefikv1alpha1 "github.com/traefik/traefik/v2/pkg/provider/kubernetes/crd/traefik/v1alpha1"
 	dnsv1alpha1 "go.linka.cloud/k8s/dns/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/json"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+	"go.linka.cloud/kube-mailserver/pkg/podexec"
 	"go.linka.cloud/kube-mailserver/pkg/resources"
 )
 
@@ -48,29 +57,62 @@ const (
 	Finalizer = "mail.linka.cloud/finalizer"
 
 	ownerKey = ".metadata.controller"
+	// fetchmailMailServerKey indexes the FetchmailSources by MailServer name
+	fetchmailMailServerKey = ".spec.mailServer"
+	// fetchmailCredentialsKey indexes the FetchmailSources by credentials secret name
+	fetchmailCredentialsKey = ".spec.credentialsSecret"
+	// secretRefsKey indexes the MailServers by referenced Secret name
+	secretRefsKey = ".spec.secretRefs"
+	// configMapRefsKey indexes the MailServers by referenced ConfigMap name
+	configMapRefsKey = ".spec.configMapRefs"
 
-	restartAnnotation = "mail.linka.cloud/restart"
+	// SRSRotateAnnotation rotates the SRS secret when its value changes, the previous secrets are kept for validation
+	SRSRotateAnnotation = "mail.linka.cloud/rotate-srs-secret"
+	// srsRotatedAnnotation records on the SRS secret the last handled rotation
+	srsRotatedAnnotation = "mail.linka.cloud/srs-rotated"
+	// srsKeptSecrets is the number of SRS secrets kept, including the signing one
+	srsKeptSecrets = 3
+
+	// postmasterRotatedAnnotation records on the generated postmaster secret the last password rotation time
+	postmasterRotatedAnnotation = "mail.linka.cloud/postmaster-rotated"
+
+	// ValidCondition reports whether the MailServer spec passed the validation
+	ValidCondition = "Valid"
+	// OverridesValidCondition reports whether the configuration overrides passed the validation
+	OverridesValidCondition = "OverridesValid"
+
+	// PausedAnnotation stops the reconciliation of the MailServer resources when set to "true"
+	PausedAnnotation = "mail.linka.cloud/paused"
+
+	// drainTimeout is the maximum time to wait for the mail queue to be flushed
+	// before entering maintenance or restarting the mail server
+	drainTimeout = 5 * time.Minute
 )
 
 // MailServerReconciler reconciles a MailServer object
 type MailServerReconciler struct {
 	client.Client
-	Scheme     *runtime.Scheme
-	GoClient   *kubernetes.Clientset
-	RestConfig *rest.Config
+	Scheme *runtime.Scheme
+	// Exec runs the commands in the mail server pods
+	Exec podexec.Executor
 }
 
 // +kubebuilder:rbac:groups=mail.linka.cloud,resources=mailservers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=mail.linka.cloud,resources=mailservers/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=mail.linka.cloud,resources=mailservers/finalizers,verbs=update
-// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;update;patch;delete;exec
+// +kubebuilder:rbac:groups=mail.linka.cloud,resources=fetchmailsources,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
 // +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=dns.linka.cloud,resources=dnsrecords,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors;prometheusrules,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -83,6 +125,7 @@ func (r *MailServerReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			log.Error(err, "unable to fetch MailServer")
 			return ctrl.Result{}, err
 		}
+		deleteMetrics(req.Namespace, req.Name)
 		return ctrl.Result{}, nil
 	}
 
@@ -94,6 +137,14 @@ func (r *MailServerReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, nil
 	}
 
+	// a paused mail server is only observed: neither its references nor the LDAP server are checked,
+	// the deletion is still handled so that the finalizer never blocks it
+	if s.Spec.Paused || s.Annotations[PausedAnnotation] == "true" {
+		res := (&resources.Config{MailServer: &s}).Resources()
+		defer r.recordMetrics(ctx, &s, res)
+		return r.reconcilePaused(ctx, &s, res)
+	}
+
 	if !hasFinalizer(&s) {
 		log.V(5).Info("adding finalizer")
 		s.Finalizers = append(s.Finalizers, Finalizer)
@@ -115,11 +166,16 @@ func (r *MailServerReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, fmt.Errorf("domain cannot be changed")
 	}
 
+	if result, ok, err := r.reconcileValid(ctx, &s); !ok {
+		return r.cancelRestart(ctx, &s, result, err)
+	}
+
 	conf := resources.Config{
 		MailServer: &s,
 	}
 
 	// check for ldap secret
+	var bindVersion string
 	if s.Spec.Features.LDAP.Enabled {
 		var bindCreds corev1.Secret
 		if err := r.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: s.Spec.Features.LDAP.BindSecret}, &bindCreds); err != nil {
@@ -136,41 +192,132 @@ func (r *MailServerReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		}
 		conf.BindDN = string(dn)
 		conf.BindPW = string(password)
+		bindVersion = bindCreds.ResourceVersion
+	}
+
+	if result, ok, err := r.reconcileLDAPReady(ctx, &s, conf.BindDN, conf.BindPW, bindVersion); !ok {
+		return r.cancelRestart(ctx, &s, result, err)
+	}
+
+	if o := s.Spec.Features.OIDC; o.Enabled && o.ClientSecret != nil {
+		var secret corev1.Secret
+		if err := r.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: o.ClientSecret.Name}, &secret); err != nil {
+			log.Error(err, "unable to fetch OIDC client secret")
+			return ctrl.Result{}, err
+		}
+		v, ok := secret.Data[o.ClientSecret.Key]
+		if !ok {
+			return ctrl.Result{}, fmt.Errorf("%s not found in OIDC client secret", o.ClientSecret.Key)
+		}
+		conf.OIDCClientSecret = string(v)
+	}
+
+	conf.Credentials = make(map[string]resources.Credentials)
+	if s.Spec.Relay != nil {
+		if err := r.relayCredentials(ctx, &s, conf.Credentials); err != nil {
+			log.Error(err, "unable to fetch relay credentials")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if s.Spec.Features.Rspamd.Enabled {
+		if err := r.rspamdPasswords(ctx, &s, conf.Credentials); err != nil {
+			log.Error(err, "unable to fetch rspamd passwords")
+			return ctrl.Result{}, err
+		}
+	}
+
+	sources, err := r.fetchmailSources(ctx, &s, conf.Credentials)
+	if err != nil {
+		log.Error(err, "unable to list fetchmail sources")
+		return ctrl.Result{}, err
 	}
+	conf.FetchmailSources = sources
+
+	overrides, err := r.overrides(ctx, &s)
+	if err != nil {
+		log.Error(err, "unable to fetch configuration overrides")
+		return ctrl.Result{}, err
+	}
+	// only the validated overrides are mounted, the new ones are held until they pass the validation
+	validated, err := r.validatedOverrides(ctx, &s)
+	if err != nil {
+		log.Error(err, "unable to fetch validated configuration overrides")
+		return ctrl.Result{}, err
+	}
+	conf.Overrides = validated
+
+	rules, err := r.spamassassinRules(ctx, &s)
+	if err != nil {
+		log.Error(err, "unable to fetch spamassassin rules")
+		return ctrl.Result{}, err
+	}
+	conf.SpamassassinRules = rules
 
 	res := conf.Resources()
+	defer r.recordMetrics(ctx, &s, res)
+
+	if r, ok, err := r.reconcileMaintenance(ctx, &s, res); !ok {
+		return r, err
+	}
+
+	if r, ok, err := observeStep(ctx, "credentials", &s, res, r.reconcileCredentials); !ok {
+		return r, err
+	}
 
-	if r, ok, err := r.reconcileCredentials(ctx, &s, res); !ok {
+	if r, ok, err := r.reconcileSRSSecret(ctx, &s, res); !ok {
 		return r, err
 	}
 
-	if r, ok, err := r.reconcileResources(ctx, &s, res); !ok {
+	if result, ok, err := r.reconcileOverrides(ctx, &s, overrides, res); !ok {
+		return r.cancelRestart(ctx, &s, result, err)
+	}
+
+	checksum, err := r.configChecksum(ctx, &s, &conf, res)
+	if err != nil {
+		log.Error(err, "unable to compute configuration checksum")
+		return ctrl.Result{}, err
+	}
+	resources.SetPodAnnotation(res.MailServer.Deployment, resources.ConfigChecksumAnnotation, checksum)
+	// the desired deployment is kept as its pod template is held while draining
+	desired := res.MailServer.Deployment.DeepCopy()
+
+	if r, ok, err := observeStep(ctx, "resources", &s, res, r.reconcileResources); !ok {
 		return r, err
 	}
 
-	if r, ok, err := r.reconcileARecord(ctx, &s, res); !ok {
+	if r, ok, err := r.reconcileRestart(ctx, &s, desired, res); !ok {
 		return r, err
 	}
 
-	if r, ok, err := r.reconcileReplicas(ctx, &s, res); !ok {
+	if r, ok, err := r.reconcileSpamLearning(ctx, &s, res); !ok {
 		return r, err
 	}
 
-	// TODO(adphi): restart deploy when certificates are renewed
+	if r, ok, err := observeStep(ctx, "a_record", &s, res, r.reconcileARecord); !ok {
+		return r, err
+	}
+
+	if r, ok, err := observeStep(ctx, "replicas", &s, res, r.reconcileReplicas); !ok {
+		return r, err
+	}
 
 	// set mailserver public IP (e.g. `curl ifconfig.me`) in spf record: v=spf1 a mx ip4:$PUBLIC_IP -all
-	if r, ok, err := r.reconcileSPF(ctx, &s, res); !ok {
+	if r, ok, err := observeStep(ctx, "spf", &s, res, r.reconcileSPF); !ok {
 		return r, err
 	}
 
 	// get dkim key from deployment
-	if r, ok, err := r.reconcileDKIM(ctx, &s, res); !ok {
+	if r, ok, err := observeStep(ctx, "dkim", &s, res, r.reconcileDKIM); !ok {
 		return r, err
 	}
-	return ctrl.Result{}, nil
+
+	// apply the postmaster password to the mailbox and come back for the next rotation
+	return r.reconcilePostmaster(ctx, &s, res)
 }
 
 func (r *MailServerReconciler) ReconcileDelete(ctx context.Context, s *mailv1alpha1.MailServer) error {
+	deleteMetrics(s.Namespace, s.Name)
 	// garbage collection should handle cleaning by itself with the resource owner references
 	if removeFinalizer(s) {
 		return r.Update(ctx, s)
@@ -178,8 +325,240 @@ func (r *MailServerReconciler) ReconcileDelete(ctx context.Context, s *mailv1alp
 	return nil
 }
 
+// credentials reads the username and password keys of the named secret
+func (r *MailServerReconciler) credentials(ctx context.Context, namespace, name string) (resources.Credentials, error) {
+	var secret corev1.Secret
+	if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &secret); err != nil {
+		return resources.Credentials{}, err
+	}
+	username, ok := secret.Data["username"]
+	if !ok {
+		return resources.Credentials{}, fmt.Errorf("username not found in credentials secret %s", name)
+	}
+	password, ok := secret.Data["password"]
+	if !ok {
+		return resources.Credentials{}, fmt.Errorf("password not found in credentials secret %s", name)
+	}
+	return resources.Credentials{Username: string(username), Password: string(password)}, nil
+}
+
+// relayCredentials reads the relay credentials secrets
+func (r *MailServerReconciler) relayCredentials(ctx context.Context, s *mailv1alpha1.MailServer, creds map[string]resources.Credentials) error {
+	names := []string{s.Spec.Relay.CredentialsSecret}
+	for _, v := range s.Spec.Relay.Domains {
+		names = append(names, v.CredentialsSecret)
+	}
+	for _, v := range names {
+		if _, ok := creds[v]; ok || v == "" {
+			continue
+		}
+		c, err := r.credentials(ctx, s.Namespace, v)
+		if err != nil {
+			return err
+		}
+		creds[v] = c
+	}
+	return nil
+}
+
+// rspamdPasswords reads the Rspamd Redis and web interface password secrets, only the password key is used
+func (r *MailServerReconciler) rspamdPasswords(ctx context.Context, s *mailv1alpha1.MailServer, creds map[string]resources.Credentials) error {
+	for _, v := range []string{s.Spec.Features.Rspamd.Redis.PasswordSecret, s.Spec.Features.Rspamd.WebUI.PasswordSecret} {
+		if _, ok := creds[v]; ok || v == "" {
+			continue
+		}
+		var secret corev1.Secret
+		if err := r.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: v}, &secret); err != nil {
+			return err
+		}
+		password, ok := secret.Data["password"]
+		if !ok {
+			return fmt.Errorf("password not found in secret %s", v)
+		}
+		creds[v] = resources.Credentials{Password: string(password)}
+	}
+	return nil
+}
+
+// fetchmailSources lists the MailServer fetchmail sources and reads their credentials.
+// The sources with invalid credentials are skipped, their status is reported by the FetchmailSource reconciler.
+func (r *MailServerReconciler) fetchmailSources(ctx context.Context, s *mailv1alpha1.MailServer, creds map[string]resources.Credentials) ([]mailv1alpha1.FetchmailSource, error) {
+	log := ctrl.LoggerFrom(ctx)
+	var list mailv1alpha1.FetchmailSourceList
+	if err := r.List(ctx, &list, client.InNamespace(s.Namespace), client.MatchingFields{fetchmailMailServerKey: s.Name}); err != nil {
+		return nil, err
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Name < list.Items[j].Name
+	})
+	var sources []mailv1alpha1.FetchmailSource
+	for _, v := range list.Items {
+		if !v.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if _, ok := creds[v.Spec.CredentialsSecret]; !ok {
+			c, err := r.credentials(ctx, s.Namespace, v.Spec.CredentialsSecret)
+			if err != nil {
+				log.Error(err, "skipping fetchmail source", "name", v.Name)
+				continue
+			}
+			creds[v.Spec.CredentialsSecret] = c
+		}
+		sources = append(sources, v)
+	}
+	return sources, nil
+}
+
+// overrides reads the configuration overrides from the referenced ConfigMaps
+func (r *MailServerReconciler) overrides(ctx context.Context, s *mailv1alpha1.MailServer) (map[string][]byte, error) {
+	overrides := make(map[string][]byte)
+	for k, v := range resources.Overrides(s) {
+		var cm corev1.ConfigMap
+		if err := r.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: v.Name}, &cm); err != nil {
+			return nil, err
+		}
+		data, ok := cm.Data[v.Key]
+		if !ok {
+			return nil, fmt.Errorf("%s not found in configmap %s", v.Key, v.Name)
+		}
+		overrides[k] = []byte(data)
+	}
+	return overrides, nil
+}
+
+// validatedOverrides reads the last validated configuration overrides from the overrides secret
+func (r *MailServerReconciler) validatedOverrides(ctx context.Context, s *mailv1alpha1.MailServer) (map[string][]byte, error) {
+	var secret corev1.Secret
+	if err := r.Get(ctx, client.ObjectKeyFromObject(resources.MailServerOverridesSecret(s, nil)), &secret); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	return secret.Data, nil
+}
+
+// spamassassinRules reads the extra SpamAssassin rules files from the rules ConfigMaps
+func (r *MailServerReconciler) spamassassinRules(ctx context.Context, s *mailv1alpha1.MailServer) (map[string][]byte, error) {
+	rules := make(map[string][]byte)
+	for _, v := range s.Spec.Features.SpamassassinConfig.Rules {
+		var cm corev1.ConfigMap
+		if err := r.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: v.Name}, &cm); err != nil {
+			return nil, err
+		}
+		for k, data := range cm.Data {
+			if strings.HasSuffix(k, ".cf") {
+				rules[v.Name+"/"+k] = []byte(data)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// reconcileValid validates the spec and reports the result in the Valid condition.
+// An invalid spec is not requeued: it is only validated again once its generation changes.
+func (r *MailServerReconciler) reconcileValid(ctx context.Context, s *mailv1alpha1.MailServer) (ctrl.Result, bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	if c := meta.FindStatusCondition(s.Status.Conditions, ValidCondition); c != nil && c.Status == metav1.ConditionFalse && c.ObservedGeneration == s.Generation {
+		return ctrl.Result{}, false, nil
+	}
+	conditions := append([]metav1.Condition(nil), s.Status.Conditions...)
+	condition := metav1.Condition{
+		Type:               ValidCondition,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: s.Generation,
+		Reason:             "Valid",
+		Message:            "MailServer spec is valid",
+	}
+	validErr := s.Validate()
+	if validErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Invalid"
+		condition.Message = validErr.Error()
+	}
+	meta.SetStatusCondition(&conditions, condition)
+	if !equality.Semantic.DeepEqual(conditions, s.Status.Conditions) {
+		s.Status.Conditions = conditions
+		if err := r.Status().Update(ctx, s); err != nil {
+			log.Error(err, "unable to update Valid condition")
+			return ctrl.Result{}, false, err
+		}
+	}
+	if validErr != nil {
+		log.Info("invalid mail server configuration, waiting for a spec change", "error", validErr.Error())
+		return ctrl.Result{}, false, nil
+	}
+	return ctrl.Result{}, true, nil
+}
+
+// reconcilePaused only updates the status, leaving the resources untouched.
+func (r *MailServerReconciler) reconcilePaused(ctx context.Context, s *mailv1alpha1.MailServer, res *resources.Resources) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+	log.V(5).Info("reconciliation paused")
+	if ok, err := r.setMode(ctx, s, mailv1alpha1.ModePaused); !ok {
+		return ctrl.Result{}, err
+	}
+	result, _, err := r.reconcileReplicas(ctx, s, res)
+	return result, client.IgnoreNotFound(err)
+}
+
+// reconcileMaintenance flushes the mail queue before entering the maintenance mode,
+// the mail server deployment is scaled down to zero by the resources reconciliation once in maintenance.
+func (r *MailServerReconciler) reconcileMaintenance(ctx context.Context, s *mailv1alpha1.MailServer, res *resources.Resources) (ctrl.Result, bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	if !s.Spec.Maintenance {
+		if s.Status.Mode == mailv1alpha1.ModeDraining {
+			log.Info("maintenance cancelled, accepting connections again")
+			if err := r.undrainQueue(ctx, res.MailServer.Deployment); err != nil {
+				log.Error(err, "unable to accept connections again")
+				return ctrl.Result{}, false, err
+			}
+		}
+		ok, err := r.setMode(ctx, s, mailv1alpha1.ModeRunning)
+		return ctrl.Result{}, ok, err
+	}
+	switch s.Status.Mode {
+	case mailv1alpha1.ModeMaintenance:
+		return ctrl.Result{}, true, nil
+	case mailv1alpha1.ModeDraining:
+	default:
+		log.Info("draining mail server before maintenance")
+		ok, err := r.setMode(ctx, s, mailv1alpha1.ModeDraining)
+		return ctrl.Result{}, ok, err
+	}
+	count, ok, err := r.drainQueue(ctx, res.MailServer.Deployment)
+	if err != nil {
+		log.Error(err, "unable to flush mail queue")
+		return ctrl.Result{}, false, err
+	}
+	if ok && count != 0 && !timedOut(s.Status.ModeTransitionTime) {
+		log.Info("waiting for the mail queue to be flushed", "messages", count)
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, false, nil
+	}
+	log.Info("entering maintenance")
+	ok, err = r.setMode(ctx, s, mailv1alpha1.ModeMaintenance)
+	return ctrl.Result{}, ok, err
+}
+
+// setMode updates the status mode, it returns false if the status was updated.
+func (r *MailServerReconciler) setMode(ctx context.Context, s *mailv1alpha1.MailServer, mode mailv1alpha1.Mode) (bool, error) {
+	if s.Status.Mode == mode {
+		return true, nil
+	}
+	s.Status.Mode = mode
+	s.Status.ModeTransitionTime = &metav1.Time{Time: time.Now()}
+	if err := r.Status().Update(ctx, s); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "unable to update mode status")
+		return false, err
+	}
+	return false, nil
+}
+
+// reconcileCredentials creates the generated postmaster secret, keeps its address up to date
+// and rotates its password when the rotation interval has elapsed.
+// The existing secrets are left to their owner.
 func (r *MailServerReconciler) reconcileCredentials(ctx context.Context, s *mailv1alpha1.MailServer, res *resources.Resources) (ctrl.Result, bool, error) {
 	log := ctrl.LoggerFrom(ctx)
+	if res.MailServer.CredsSecret == nil {
+		return ctrl.Result{}, true, nil
+	}
 
 	ps := &corev1.Secret{}
 	log.V(5).Info("looking for credentials secret", "name", res.MailServer.CredsSecret.Name)
@@ -188,6 +567,7 @@ func (r *MailServerReconciler) reconcileCredentials(ctx context.Context, s *mail
 			return ctrl.Result{}, false, err
 		}
 		ps = res.MailServer.CredsSecret
+		ps.Annotations = map[string]string{postmasterRotatedAnnotation: time.Now().UTC().Format(time.RFC3339)}
 		log.Info("creating credentials secret", "name", res.MailServer.CredsSecret)
 		if err := ctrl.SetControllerReference(s, ps, r.Scheme); err != nil {
 			return ctrl.Result{}, false, err
@@ -195,12 +575,256 @@ func (r *MailServerReconciler) reconcileCredentials(ctx context.Context, s *mail
 		if err := r.Create(ctx, ps); err != nil {
 			return ctrl.Result{}, false, err
 		}
-	} else {
-		log.V(5).Info("credentials secret already exists", "name", res.MailServer.CredsSecret)
+		return ctrl.Result{}, true, nil
+	}
+	log.V(5).Info("credentials secret already exists", "name", res.MailServer.CredsSecret)
+	update := false
+	// the secret may have been emptied by hand or by another tool
+	if ps.Data == nil {
+		ps.Data = make(map[string][]byte)
+	}
+	if len(ps.Data["password"]) == 0 {
+		log.Info("generating missing postmaster password", "name", ps.Name)
+		ps.Data["password"] = []byte(resources.RandomPassword())
+		update = true
+	}
+	if email := res.MailServer.CredsSecret.Data["email"]; !bytes.Equal(ps.Data["email"], email) {
+		log.Info("updating postmaster address", "name", ps.Name)
+		ps.Data["email"] = email
+		update = true
+	}
+	if i := s.Spec.Postmaster.RotationInterval; i != nil && !time.Now().Before(postmasterRotated(ps).Add(i.Duration)) {
+		log.Info("rotating postmaster password", "name", ps.Name)
+		ps.Data["password"] = []byte(resources.RandomPassword())
+		if ps.Annotations == nil {
+			ps.Annotations = make(map[string]string)
+		}
+		ps.Annotations[postmasterRotatedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		update = true
+	}
+	if !update {
+		return ctrl.Result{}, true, nil
+	}
+	if err := r.Update(ctx, ps); err != nil {
+		log.Error(err, "unable to update credentials secret")
+		return ctrl.Result{}, false, err
 	}
 	return ctrl.Result{}, true, nil
 }
 
+// postmasterRotated returns the last rotation time of the generated postmaster secret,
+// the secrets created before the rotation support are considered rotated at their creation
+func postmasterRotated(secret *corev1.Secret) time.Time {
+	if t, err := time.Parse(time.RFC3339, secret.Annotations[postmasterRotatedAnnotation]); err == nil {
+		return t
+	}
+	return secret.CreationTimestamp.Time
+}
+
+// reconcilePostmaster applies the postmaster password to the mailbox of the running mail server when the secret changed,
+// as the setup init container only runs when a pod starts.
+// It requeues the MailServer for the next password rotation.
+func (r *MailServerReconciler) reconcilePostmaster(ctx context.Context, s *mailv1alpha1.MailServer, res *resources.Resources) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+	var secret corev1.Secret
+	if err := r.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: resources.PostmasterSecretName(s)}, &secret); err != nil {
+		log.Error(err, "unable to fetch postmaster secret")
+		return ctrl.Result{}, err
+	}
+	address := resources.PostmasterAddress(s)
+	if st := s.Status.Postmaster; st == nil || st.Address != address || st.SecretResourceVersion != secret.ResourceVersion {
+		password, ok := secret.Data["password"]
+		if !ok {
+			return ctrl.Result{}, fmt.Errorf("password not found in postmaster secret %s", secret.Name)
+		}
+		// the setup init container applies it when the mail server is scaled up again
+		if resources.V(res.MailServer.Deployment.Spec.Replicas) == 0 {
+			return ctrl.Result{}, nil
+		}
+		log.Info("applying postmaster password", "address", address)
+		if _, ok, err := r.execDeployStdin(ctx, res.MailServer.Deployment, strings.NewReader(string(password)+"\n"), resources.MailServerPostmasterCommand(address)...); err != nil {
+			log.Error(err, "unable to apply postmaster password")
+			return ctrl.Result{}, err
+		} else if !ok {
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+		}
+		status := &mailv1alpha1.PostmasterStatus{
+			Address:               address,
+			SecretResourceVersion: secret.ResourceVersion,
+		}
+		if _, ok := secret.Annotations[postmasterRotatedAnnotation]; ok {
+			status.LastRotationTime = &metav1.Time{Time: postmasterRotated(&secret)}
+		}
+		s.Status.Postmaster = status
+		if err := r.Status().Update(ctx, s); err != nil {
+			log.Error(err, "unable to update postmaster status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if i := s.Spec.Postmaster.RotationInterval; i != nil && res.MailServer.CredsSecret != nil {
+		return ctrl.Result{RequeueAfter: time.Until(postmasterRotated(&secret).Add(i.Duration))}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// reconcileSRSSecret creates the SRS secret once and rotates it when requested.
+// The secret is never regenerated, otherwise the bounces of the already forwarded mails would be rejected.
+func (r *MailServerReconciler) reconcileSRSSecret(ctx context.Context, s *mailv1alpha1.MailServer, res *resources.Resources) (ctrl.Result, bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	if !s.Spec.Features.SRS.Enabled {
+		return ctrl.Result{}, true, nil
+	}
+	rotation := s.Annotations[SRSRotateAnnotation]
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(res.MailServer.SRSSecret), secret); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, false, err
+		}
+		secret = res.MailServer.SRSSecret
+		secret.Annotations = map[string]string{srsRotatedAnnotation: rotation}
+		log.Info("creating srs secret", "name", secret.Name)
+		if err := ctrl.SetControllerReference(s, secret, r.Scheme); err != nil {
+			return ctrl.Result{}, false, err
+		}
+		if err := r.Create(ctx, secret); err != nil {
+			return ctrl.Result{}, false, err
+		}
+		return ctrl.Result{}, true, nil
+	}
+	if rotation == "" || secret.Annotations[srsRotatedAnnotation] == rotation {
+		return ctrl.Result{}, true, nil
+	}
+	log.Info("rotating srs secret", "name", secret.Name)
+	secrets := append([]string{string(res.MailServer.SRSSecret.Data["secrets"])}, strings.Split(string(secret.Data["secrets"]), ",")...)
+	if len(secrets) > srsKeptSecrets {
+		secrets = secrets[:srsKeptSecrets]
+	}
+	secret.Data["secrets"] = []byte(strings.Join(secrets, ","))
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Annotations[srsRotatedAnnotation] = rotation
+	if err := r.Update(ctx, secret); err != nil {
+		log.Error(err, "unable to rotate srs secret")
+		return ctrl.Result{}, false, err
+	}
+	// the secret is part of the configuration checksum, the mail server is restarted by the resources reconciliation
+	return ctrl.Result{}, false, nil
+}
+
+// reconcileOverrides validates the Postfix overrides against the running mail server configuration before they are rolled out.
+// The validated overrides are written to the overrides secret, which is the only one mounted in the mail server,
+// and their checksum is recorded in the status so that they are validated only once.
+// The result is reported in the OverridesValid condition: invalid overrides are held without being requeued,
+// the mail server keeps running with the last validated ones.
+func (r *MailServerReconciler) reconcileOverrides(ctx context.Context, s *mailv1alpha1.MailServer, overrides map[string][]byte, res *resources.Resources) (ctrl.Result, bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	checksum := ""
+	if len(overrides) != 0 {
+		checksum = resources.OverridesChecksum(overrides)
+	}
+	if s.Status.OverridesChecksum == checksum {
+		return ctrl.Result{}, true, nil
+	}
+	conditions := append([]metav1.Condition(nil), s.Status.Conditions...)
+	condition := metav1.Condition{
+		Type:               OverridesValidCondition,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: s.Generation,
+		Reason:             "Valid",
+		Message:            "configuration overrides are valid",
+	}
+	main, master := overrides[resources.PostfixMainFile], overrides[resources.PostfixMasterFile]
+	if len(main) != 0 || len(master) != 0 {
+		log.Info("validating postfix overrides")
+		out, ok, err := r.execDeployOut(ctx, res.MailServer.Deployment, postfixValidateCommand(main, master)...)
+		var exitErr *podexec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			log.Error(err, "unable to validate postfix overrides")
+			return ctrl.Result{}, false, err
+		}
+		if !ok && err == nil {
+			// nothing to validate against, e.g. on first deployment or in maintenance:
+			// the last validated overrides are kept until a pod is ready
+			return ctrl.Result{}, true, nil
+		}
+		if out = strings.TrimSpace(out); err != nil || strings.Contains(out, "warning:") || strings.Contains(out, "fatal:") {
+			if out == "" {
+				out = err.Error()
+			}
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "Invalid"
+			condition.Message = "invalid postfix overrides: " + out
+		}
+	}
+	meta.SetStatusCondition(&conditions, condition)
+	if condition.Status == metav1.ConditionFalse {
+		if equality.Semantic.DeepEqual(conditions, s.Status.Conditions) {
+			return ctrl.Result{}, true, nil
+		}
+		log.Info("invalid postfix overrides, keeping the last validated ones", "error", condition.Message)
+		s.Status.Conditions = conditions
+		if err := r.Status().Update(ctx, s); err != nil {
+			log.Error(err, "unable to update OverridesValid condition")
+			return ctrl.Result{}, false, err
+		}
+		return ctrl.Result{}, false, nil
+	}
+	if err := r.applyOverrides(ctx, s, overrides, res); err != nil {
+		log.Error(err, "unable to write validated overrides")
+		return ctrl.Result{}, false, err
+	}
+	if len(overrides) == 0 {
+		meta.RemoveStatusCondition(&conditions, OverridesValidCondition)
+	}
+	s.Status.Conditions = conditions
+	s.Status.OverridesChecksum = checksum
+	if err := r.Status().Update(ctx, s); err != nil {
+		log.Error(err, "unable to update overrides checksum status")
+		return ctrl.Result{}, false, err
+	}
+	return ctrl.Result{}, false, nil
+}
+
+// applyOverrides writes the validated overrides to the overrides secret, or deletes it when there are no overrides left.
+func (r *MailServerReconciler) applyOverrides(ctx context.Context, s *mailv1alpha1.MailServer, overrides map[string][]byte, res *resources.Resources) error {
+	want := resources.MailServerOverridesSecret(s, overrides)
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(want), secret); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		if len(overrides) == 0 {
+			return nil
+		}
+		if err := ctrl.SetControllerReference(s, want, r.Scheme); err != nil {
+			return err
+		}
+		return r.Create(ctx, want)
+	}
+	if len(overrides) == 0 {
+		return client.IgnoreNotFound(r.Delete(ctx, secret))
+	}
+	secret.Labels = want.Labels
+	secret.Data = want.Data
+	return r.Update(ctx, secret)
+}
+
+// postfixValidateCommand applies the overrides to a copy of the running Postfix configuration and checks it with postconf,
+// the same way docker-mailserver applies them at startup.
+// postconf prints its warnings on stderr and still exits 0, so stderr is the checked output.
+func postfixValidateCommand(main, master []byte) []string {
+	return podexec.Shell(
+		`d=$(mktemp -d) && trap 'rm -rf $d' EXIT && cp /etc/postfix/main.cf /etc/postfix/master.cf $d/ && `+
+			`echo "$1" | base64 -d >> $d/main.cf && `+
+			`echo "$2" | base64 -d | while read -r l; do [ -z "$l" ] || [ "${l#\#}" != "$l" ] || postconf -c $d -P "$l" 2>&1 || exit 1; done && `+
+			`postconf -c $d -n 2>&1 >/dev/null`,
+		base64.StdEncoding.EncodeToString(main),
+		base64.StdEncoding.EncodeToString(master),
+	)
+}
+
 func (r *MailServerReconciler) reconcileResources(ctx context.Context, s *mailv1alpha1.MailServer, res *resources.Resources) (ctrl.Result, bool, error) {
 	log := ctrl.LoggerFrom(ctx)
 	// generate manifests
@@ -208,50 +832,14 @@ func (r *MailServerReconciler) reconcileResources(ctx context.Context, s *mailv1
 
 	var restart bool
 
-	var mres = []client.Object{
-		res.MailServer.ConfigSecret,
-		res.MailServer.Cert,
-		res.MailServer.PVC,
-		res.MailServer.Deployment,
-		res.MailServer.Service,
-		res.MailServer.DNS.MX,
-		res.MailServer.DNS.SPF,
-		res.MailServer.DNS.DMARC,
-		res.MailServer.DNS.IMAP,
-		res.MailServer.DNS.IMAPs,
-		res.MailServer.DNS.POP3,
-		res.MailServer.DNS.POP3s,
-		res.MailServer.DNS.Submission,
-	}
-	ares := []client.Object{
-		res.AutoConfig.Cert,
-		res.AutoConfig.Deployment,
-		res.AutoConfig.Service,
-		res.AutoConfig.AutoDiscoverRecord,
-	}
-	tres := []client.Object{
-		res.AutoConfig.TraefikIngressRoutes.Redirect2HTTPs,
-		res.AutoConfig.TraefikIngressRoutes.Route,
-		res.AutoConfig.TraefikIngressRoutes.RouteTLS,
-	}
+	mres := res.Objects()
 	autoConfigEnabled := s.Spec.AutoConfig.Enabled == nil || *s.Spec.AutoConfig.Enabled
-	if autoConfigEnabled {
-		mres = append(mres, ares...)
-		if s.Spec.Traefik != nil && s.Spec.Traefik.CRDs {
-			mres = append(mres, tres...)
-		} else {
-			mres = append(mres, res.AutoConfig.Ingress)
-		}
-	}
+	traefikEnabled := autoConfigEnabled && s.Spec.Traefik != nil && s.Spec.Traefik.CRDs
 
 	ok := true
 
 	// create/update resources
 	for _, v := range mres {
-		// skip nil resources, e.g. traefik ingress
-		if v == nil {
-			continue
-		}
 		if v, ok := interface{}(v).(interface{ Default() }); ok {
 			v.Default()
 		}
@@ -272,16 +860,20 @@ func (r *MailServerReconciler) reconcileResources(ctx context.Context, s *mailv1
 				return ctrl.Result{}, false, err
 			}
 			ok = false
+			continue
 		}
 		var equals bool
 		switch v {
-		case res.MailServer.ConfigSecret:
-			data1, _ := json.Marshal(v.(*corev1.Secret).Data)
-			data2, _ := json.Marshal(got.(*corev1.Secret).Data)
-			if equals = bytes.Equal(data1, data2); equals {
-				continue
+		case res.MailServer.Deployment:
+			held, err := r.holdPodTemplate(ctx, s, v.(*appsv1.Deployment), got.(*appsv1.Deployment))
+			if err != nil {
+				log.Error(err, "unable to dry-run update mail server deployment")
+				return ctrl.Result{}, false, err
 			}
-			restart = true
+			if held && s.Status.Restart == nil {
+				restart = true
+			}
+			equals = equality.Semantic.DeepDerivative(v.(*appsv1.Deployment).Spec, got.(*appsv1.Deployment).Spec) && samePorts(v, got)
 		case res.MailServer.PVC:
 			pvc := got.(*corev1.PersistentVolumeClaim)
 			size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
@@ -306,18 +898,28 @@ func (r *MailServerReconciler) reconcileResources(ctx context.Context, s *mailv1
 			continue
 		default:
 			switch v := v.(type) {
+			case *corev1.Secret:
+				// DeepDerivative ignores the empty values, e.g. an unset configuration variable
+				equals = equality.Semantic.DeepEqual(v.Data, got.(*corev1.Secret).Data)
 			case *cmv1.Certificate:
 				equals = equality.Semantic.DeepDerivative(v.Spec, got.(*cmv1.Certificate).Spec)
+			case *batchv1.CronJob:
+				equals = equality.Semantic.DeepDerivative(v.Spec, got.(*batchv1.CronJob).Spec)
+			case *corev1.PersistentVolumeClaim:
+				equals = equality.Semantic.DeepDerivative(v.Spec, got.(*corev1.PersistentVolumeClaim).Spec)
 			case *appsv1.Deployment:
-				equals = equality.Semantic.DeepDerivative(v.Spec, got.(*appsv1.Deployment).Spec)
+				equals = equality.Semantic.DeepDerivative(v.Spec, got.(*appsv1.Deployment).Spec) && samePorts(v, got)
 			case *corev1.Service:
-				equals = equality.Semantic.DeepDerivative(v.Spec, got.(*corev1.Service).Spec)
+				equals = equality.Semantic.DeepDerivative(v.Spec, got.(*corev1.Service).Spec) && samePorts(v, got)
 			case *dnsv1alpha1.DNSRecord:
 				equals = equality.Semantic.DeepDerivative(v.Spec, got.(*dnsv1alpha1.DNSRecord).Spec)
 			case *traefikv1alpha1.IngressRoute:
 				equals = equality.Semantic.DeepDerivative(v.Spec, got.(*traefikv1alpha1.IngressRoute).Spec)
 			case *traefikv1alpha1.Middleware:
 				equals = equality.Semantic.DeepDerivative(v.Spec, got.(*traefikv1alpha1.Middleware).Spec)
+			case *unstructured.Unstructured:
+				got := got.(*unstructured.Unstructured)
+				equals = equality.Semantic.DeepDerivative(v.Object["spec"], got.Object["spec"]) && equality.Semantic.DeepDerivative(v.GetLabels(), got.GetLabels())
 			default:
 				equals = equality.Semantic.DeepDerivative(v, got)
 			}
@@ -333,7 +935,7 @@ func (r *MailServerReconciler) reconcileResources(ctx context.Context, s *mailv1
 			log.Error(err, "unable to dry-run update resource")
 			return ctrl.Result{}, false, err
 		}
-		if v != res.MailServer.ConfigSecret && equality.Semantic.DeepDerivative(v, got) {
+		if _, isSecret := v.(*corev1.Secret); !isSecret && equality.Semantic.DeepDerivative(v, got) && samePorts(v, got) {
 			log.V(5).Info("resource up to date after dry-run", "kind", got.GetObjectKind().GroupVersionKind().Kind, "name", got.GetName())
 			continue
 		}
@@ -344,30 +946,14 @@ func (r *MailServerReconciler) reconcileResources(ctx context.Context, s *mailv1
 		ok = false
 	}
 	if restart {
-		if err := r.restartMailServer(ctx, s, res); err != nil {
-			return ctrl.Result{}, false, err
-		}
-		return ctrl.Result{}, false, nil
+		return ctrl.Result{}, false, r.requestRestart(ctx, s)
 	}
 	if !ok {
 		return ctrl.Result{}, false, nil
 	}
 
-	if !autoConfigEnabled {
-		for _, v := range ares {
-			if err := r.Delete(ctx, v); err != nil {
-				if client.IgnoreNotFound(err) != nil {
-					log.Error(err, "unable to delete resource", "kind", v.GetObjectKind().GroupVersionKind().Kind, "name", v.GetName())
-					return ctrl.Result{}, false, err
-				}
-			} else {
-				log.Info("deleted resource", "kind", v.GetObjectKind().GroupVersionKind().Kind, "name", v.GetName())
-				ok = false
-			}
-		}
-	}
-	if !ok {
-		return ctrl.Result{}, false, nil
+	if ok, err := r.pruneResources(ctx, s, res); err != nil || !ok {
+		return ctrl.Result{}, false, err
 	}
 	if s.Status.AutoConfig == nil || *s.Status.AutoConfig != autoConfigEnabled {
 		s.Status.AutoConfig = &autoConfigEnabled
@@ -377,51 +963,382 @@ func (r *MailServerReconciler) reconcileResources(ctx context.Context, s *mailv1
 		}
 		return ctrl.Result{}, false, nil
 	}
-	if s.Spec.Traefik == nil || !s.Spec.Traefik.CRDs {
-		for _, v := range tres {
-			if err := r.Delete(ctx, v); err != nil {
-				if client.IgnoreNotFound(err) != nil {
-					log.Error(err, "unable to delete resource", "kind", v.GetObjectKind().GroupVersionKind().Kind, "name", v.GetName())
-					return ctrl.Result{}, false, err
-				}
-			} else {
-				log.Info("deleted resource", "kind", v.GetObjectKind().GroupVersionKind().Kind, "name", v.GetName())
-				ok = false
+	if s.Status.Traefik == nil || *s.Status.Traefik != traefikEnabled {
+		s.Status.Traefik = &traefikEnabled
+		if err := r.Status().Update(ctx, s); err != nil {
+			log.Error(err, "unable to update traefik crds status")
+			return ctrl.Result{}, false, err
+		}
+		return ctrl.Result{}, false, nil
+	}
+	return ctrl.Result{}, true, nil
+}
+
+// pruneResources deletes the resources owned by the MailServer that are not part of the desired resources anymore,
+// e.g. the POP3 records when POP3 is disabled or the autoconfig resources when autoconfig is disabled.
+// It returns false if some resources were deleted.
+func (r *MailServerReconciler) pruneResources(ctx context.Context, s *mailv1alpha1.MailServer, res *resources.Resources) (bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	keep := make(map[string]struct{})
+	for _, v := range res.Owned() {
+		k, err := r.objectKey(v)
+		if err != nil {
+			return false, err
+		}
+		keep[k] = struct{}{}
+	}
+	ok := true
+	for _, v := range owned {
+		list := v.list.DeepCopyObject().(client.ObjectList)
+		if err := r.List(ctx, list, client.InNamespace(s.Namespace), client.MatchingFields{ownerKey: s.Name}); err != nil {
+			log.Error(err, "unable to list owned resources")
+			return false, err
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return false, err
+		}
+		for _, v := range items {
+			o, isObject := v.(client.Object)
+			if !isObject {
+				continue
+			}
+			k, err := r.objectKey(o)
+			if err != nil {
+				return false, err
+			}
+			if _, found := keep[k]; found {
+				continue
+			}
+			log.Info("deleting orphaned resource", "resource", k)
+			if err := r.Delete(ctx, o, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+				log.Error(err, "unable to delete resource", "resource", k)
+				return false, err
 			}
+			ok = false
 		}
-	} else {
-		if err := r.Delete(ctx, res.AutoConfig.Ingress); err != nil {
+	}
+	// the Prometheus Operator resources are not watched, so they are looked up by name
+	for _, v := range []*unstructured.Unstructured{resources.MailServerServiceMonitor(s), resources.MailServerPrometheusRule(s)} {
+		k, err := r.objectKey(v)
+		if err != nil {
+			return false, err
+		}
+		if _, found := keep[k]; found {
+			continue
+		}
+		gvk := v.GroupVersionKind()
+		if _, err := r.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			if meta.IsNoMatchError(err) {
+				continue
+			}
+			return false, err
+		}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(v), v); err != nil {
 			if client.IgnoreNotFound(err) != nil {
-				log.Error(err, "unable to delete ingress")
-				return ctrl.Result{}, false, err
+				log.Error(err, "unable to fetch resource", "resource", k)
+				return false, err
 			}
-		} else {
-			log.Info("deleted ingress")
-			ok = false
+			continue
+		}
+		if !metav1.IsControlledBy(v, s) {
+			continue
+		}
+		log.Info("deleting orphaned resource", "resource", k)
+		if err := r.Delete(ctx, v); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "unable to delete resource", "resource", k)
+			return false, err
 		}
+		ok = false
 	}
-	if !ok {
-		return ctrl.Result{}, false, nil
+	return ok, nil
+}
+
+// objectKey returns a key identifying the object kind and name
+func (r *MailServerReconciler) objectKey(o client.Object) (string, error) {
+	gvk, err := apiutil.GVKForObject(o, r.Scheme)
+	if err != nil {
+		return "", err
 	}
-	if s.Status.Traefik == nil || *s.Status.Traefik != (autoConfigEnabled && s.Spec.Traefik.CRDs) {
-		s.Status.Traefik = &s.Spec.Traefik.CRDs
-		if err := r.Status().Update(ctx, s); err != nil {
-			log.Error(err, "unable to update traefik crds status")
+	return gvk.GroupKind().String() + "/" + o.GetName(), nil
+}
+
+// reconcileSpamLearning reports the Bayes learning CronJob runs in the status
+func (r *MailServerReconciler) reconcileSpamLearning(ctx context.Context, s *mailv1alpha1.MailServer, res *resources.Resources) (ctrl.Result, bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	var status *mailv1alpha1.SpamLearningStatus
+	if res.MailServer.SpamLearning != nil {
+		var job batchv1.CronJob
+		if err := r.Get(ctx, client.ObjectKeyFromObject(res.MailServer.SpamLearning), &job); err != nil {
+			log.Error(err, "unable to fetch spam learning cronjob")
 			return ctrl.Result{}, false, err
 		}
-		return ctrl.Result{}, false, nil
+		status = spamLearningStatus(&job)
 	}
-	return ctrl.Result{}, true, nil
+	if equality.Semantic.DeepEqual(status, s.Status.SpamLearning) {
+		return ctrl.Result{}, true, nil
+	}
+	s.Status.SpamLearning = status
+	if err := r.Status().Update(ctx, s); err != nil {
+		log.Error(err, "unable to update spam learning status")
+		return ctrl.Result{}, false, err
+	}
+	return ctrl.Result{}, false, nil
 }
 
-func (r *MailServerReconciler) restartMailServer(ctx context.Context, s *mailv1alpha1.MailServer, res *resources.Resources) error {
+// spamLearningStatus infers the last run result from the CronJob status,
+// the failed runs are the ones scheduled after the last successful one
+func spamLearningStatus(job *batchv1.CronJob) *mailv1alpha1.SpamLearningStatus {
+	status := &mailv1alpha1.SpamLearningStatus{
+		LastScheduleTime:   job.Status.LastScheduleTime,
+		LastSuccessfulTime: job.Status.LastSuccessfulTime,
+	}
+	switch {
+	case len(job.Status.Active) != 0:
+		status.LastResult = mailv1alpha1.SpamLearningRunning
+	case status.LastScheduleTime == nil:
+	case status.LastSuccessfulTime != nil && !status.LastSuccessfulTime.Before(status.LastScheduleTime):
+		status.LastResult = mailv1alpha1.SpamLearningSucceeded
+	default:
+		status.LastResult = mailv1alpha1.SpamLearningFailed
+	}
+	return status
+}
+
+// reconcileRestart orchestrates the pending mail server restart:
+// new connections are refused and the mail queue is flushed before the pods are rolled.
+func (r *MailServerReconciler) reconcileRestart(ctx context.Context, s *mailv1alpha1.MailServer, desired *appsv1.Deployment, res *resources.Resources) (ctrl.Result, bool, error) {
 	log := ctrl.LoggerFrom(ctx)
-	log.Info("restarting mail server")
-	var deploy appsv1.Deployment
-	if err := r.Get(ctx, client.ObjectKeyFromObject(res.MailServer.Deployment), &deploy); err != nil {
+	if s.Status.Restart == nil {
+		return ctrl.Result{}, true, nil
+	}
+	switch s.Status.Restart.Phase {
+	case mailv1alpha1.RestartPhaseDraining:
+		var deploy appsv1.Deployment
+		if err := r.Get(ctx, client.ObjectKeyFromObject(res.MailServer.Deployment), &deploy); err != nil {
+			log.Error(err, "unable to fetch mail server deployment")
+			return ctrl.Result{}, false, err
+		}
+		if samePodTemplate(desired, &deploy) {
+			log.Info("configuration reverted, cancelling restart")
+			if err := r.undrainQueue(ctx, res.MailServer.Deployment); err != nil {
+				log.Error(err, "unable to accept connections again")
+				return ctrl.Result{}, false, err
+			}
+			s.Status.Restart = nil
+			break
+		}
+		count, ok, err := r.drainQueue(ctx, res.MailServer.Deployment)
+		if err != nil {
+			log.Error(err, "unable to flush mail queue")
+			// the restart must not be held forever by a failing pod
+			if !timedOut(s.Status.Restart.StartTime) {
+				return ctrl.Result{}, false, err
+			}
+		}
+		if ok && count != 0 && !timedOut(s.Status.Restart.StartTime) {
+			log.Info("waiting for the mail queue to be flushed before restart", "messages", count)
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, false, nil
+		}
+		// the new pod template is released by the resources reconciliation
+		log.Info("restarting mail server")
+		restarts.WithLabelValues(s.Namespace, s.Name).Inc()
+		s.Status.Restart = &mailv1alpha1.RestartStatus{Phase: mailv1alpha1.RestartPhaseRolling, StartTime: &metav1.Time{Time: time.Now()}}
+	case mailv1alpha1.RestartPhaseRolling:
+		var deploy appsv1.Deployment
+		if err := r.Get(ctx, client.ObjectKeyFromObject(res.MailServer.Deployment), &deploy); err != nil {
+			log.Error(err, "unable to fetch mail server deployment")
+			return ctrl.Result{}, false, err
+		}
+		if !rolledOut(&deploy) {
+			log.V(5).Info("waiting for the mail server rollout")
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, false, nil
+		}
+		log.Info("mail server restarted")
+		s.Status.Restart = nil
+	}
+	if err := r.Status().Update(ctx, s); err != nil {
+		log.Error(err, "unable to update restart status")
+		return ctrl.Result{}, false, err
+	}
+	return ctrl.Result{}, false, nil
+}
+
+// cancelRestart accepts connections again when a reconciliation gate stops while the mail server is drained
+// for a restart, as the restart cannot run until the gate passes: it is requested again afterwards.
+// It returns the gate result and error.
+func (r *MailServerReconciler) cancelRestart(ctx context.Context, s *mailv1alpha1.MailServer, result ctrl.Result, gateErr error) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+	if s.Status.Restart == nil || s.Status.Restart.Phase != mailv1alpha1.RestartPhaseDraining || s.Spec.Maintenance {
+		return result, gateErr
+	}
+	log.Info("reconciliation stopped while draining, cancelling restart")
+	res := (&resources.Config{MailServer: s}).Resources()
+	if err := r.undrainQueue(ctx, res.MailServer.Deployment); err != nil {
+		log.Error(err, "unable to accept connections again")
+		return ctrl.Result{}, err
+	}
+	s.Status.Restart = nil
+	if err := r.Status().Update(ctx, s); err != nil {
+		log.Error(err, "unable to update restart status")
+		return ctrl.Result{}, err
+	}
+	return result, gateErr
+}
+
+// requestRestart records a pending restart in the status, it is orchestrated by reconcileRestart
+func (r *MailServerReconciler) requestRestart(ctx context.Context, s *mailv1alpha1.MailServer) error {
+	log := ctrl.LoggerFrom(ctx)
+	log.Info("mail server restart required")
+	s.Status.Restart = &mailv1alpha1.RestartStatus{Phase: mailv1alpha1.RestartPhaseDraining, StartTime: &metav1.Time{Time: time.Now()}}
+	if err := r.Status().Update(ctx, s); err != nil {
+		log.Error(err, "unable to update restart status")
+		return err
+	}
+	return nil
+}
+
+// holdPodTemplate keeps the running pod template on the deployment until the mail queue is drained,
+// so that the pods are only rolled by the orchestrated restart, whatever changed in the template:
+// the configuration checksum, the volumes, the containers, the probes...
+// It returns true if the template changed and a restart is needed.
+func (r *MailServerReconciler) holdPodTemplate(ctx context.Context, s *mailv1alpha1.MailServer, want, got *appsv1.Deployment) (bool, error) {
+	// there is nothing to drain
+	if got.Status.Replicas == 0 || s.Spec.Maintenance {
+		return false, nil
+	}
+	if s.Status.Restart != nil && s.Status.Restart.Phase == mailv1alpha1.RestartPhaseRolling {
+		return false, nil
+	}
+	if samePodTemplate(want, got) {
+		return false, nil
+	}
+	// the defaulted template tells whether the difference is only a default value
+	dry := want.DeepCopy()
+	dry.SetResourceVersion(got.GetResourceVersion())
+	if err := r.Update(ctx, dry, client.DryRunAll); err != nil {
+		return false, err
+	}
+	if equality.Semantic.DeepEqual(dry.Spec.Template, got.Spec.Template) {
+		return false, nil
+	}
+	want.Spec.Template = *got.Spec.Template.DeepCopy()
+	return true, nil
+}
+
+// samePodTemplate checks that the desired pod template is the running one,
+// the volumes and ports are counted as DeepDerivative considers a shorter desired list as equal.
+func samePodTemplate(want, got *appsv1.Deployment) bool {
+	return equality.Semantic.DeepDerivative(want.Spec.Template, got.Spec.Template) && samePorts(want, got) &&
+		len(want.Spec.Template.Spec.Volumes) == len(got.Spec.Template.Spec.Volumes)
+}
+
+// configChecksum computes the checksum of every input requiring a mail server restart when it changes:
+// the config and files secrets, the overrides, the LDAP bind and relay credentials, the LDAP TLS certificates,
+// the SRS secret and the certificate.
+func (r *MailServerReconciler) configChecksum(ctx context.Context, s *mailv1alpha1.MailServer, conf *resources.Config, res *resources.Resources) (string, error) {
+	inputs := map[string][]byte{
+		"ldap/bindDN": []byte(conf.BindDN),
+		"ldap/bindPW": []byte(conf.BindPW),
+	}
+	add := func(prefix string, data map[string][]byte) {
+		for k, v := range data {
+			inputs[prefix+"/"+k] = v
+		}
+	}
+	add("config", res.MailServer.ConfigSecret.Data)
+	if res.MailServer.FilesSecret != nil {
+		add("files", res.MailServer.FilesSecret.Data)
+	}
+	add("overrides", conf.Overrides)
+	for k, v := range conf.Credentials {
+		inputs["credentials/"+k] = []byte(v.Username + ":" + v.Password)
+	}
+	secrets := []string{res.MailServer.SRSSecret.Name, res.MailServer.Cert.Spec.SecretName}
+	var configMaps []string
+	// the LDAP client key is copied at startup
+	if l := s.Spec.Features.LDAP; l.Enabled {
+		if l.TLS.ClientCertSecret != "" {
+			secrets = append(secrets, l.TLS.ClientCertSecret)
+		}
+		if ca := l.TLS.CA; ca != nil && ca.Secret != nil {
+			secrets = append(secrets, ca.Secret.Name)
+		}
+		if ca := l.TLS.CA; ca != nil && ca.ConfigMap != nil {
+			configMaps = append(configMaps, ca.ConfigMap.Name)
+		}
+	}
+	for _, v := range secrets {
+		var secret corev1.Secret
+		if err := r.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: v}, &secret); client.IgnoreNotFound(err) != nil {
+			return "", err
+		}
+		add("secrets/"+v, secret.Data)
+	}
+	for _, name := range configMaps {
+		var cm corev1.ConfigMap
+		if err := r.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: name}, &cm); client.IgnoreNotFound(err) != nil {
+			return "", err
+		}
+		add("configmaps/"+name, cm.BinaryData)
+		for k, v := range cm.Data {
+			inputs["configmaps/"+name+"/"+k] = []byte(v)
+		}
+	}
+	return resources.Checksum(inputs), nil
+}
+
+// drainQueue stops accepting new connections on all the running mail server pods and flushes their mail queue.
+// It returns the number of messages still queued and false if no pod is running.
+func (r *MailServerReconciler) drainQueue(ctx context.Context, deploy *appsv1.Deployment) (int, bool, error) {
+	pods, err := podexec.RunningPods(ctx, r, deploy.Namespace, deploy.Spec.Template.Labels)
+	if err != nil {
+		return 0, false, err
+	}
+	var count int
+	for i := range pods {
+		out, err := r.execOut(ctx, &pods[i], podexec.Shell(resources.MailServerDrainCommand)...)
+		if err != nil {
+			return 0, false, err
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(out))
+		if err != nil {
+			return 0, false, fmt.Errorf("unexpected mail queue size: %q", out)
+		}
+		count += n
+	}
+	return count, len(pods) != 0, nil
+}
+
+// undrainQueue accepts new connections again on all the running mail server pods
+func (r *MailServerReconciler) undrainQueue(ctx context.Context, deploy *appsv1.Deployment) error {
+	pods, err := podexec.RunningPods(ctx, r, deploy.Namespace, deploy.Spec.Template.Labels)
+	if err != nil {
 		return err
 	}
-	return r.Patch(ctx, &deploy, client.RawPatch(types.MergePatchType, []byte(fmt.Sprintf(`{"spec": {"template": {"metadata": {"annotations": {"%s": "%s"}}}}}`, restartAnnotation, time.Now().UTC().Format(time.RFC3339)))))
+	for i := range pods {
+		if _, err := r.execOut(ctx, &pods[i], podexec.Shell(resources.MailServerUndrainCommand)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rolledOut returns true if all the deployment replicas are updated and available
+func rolledOut(deploy *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if deploy.Spec.Replicas != nil {
+		replicas = *deploy.Spec.Replicas
+	}
+	return deploy.Status.ObservedGeneration >= deploy.Generation &&
+		deploy.Status.UpdatedReplicas == replicas &&
+		deploy.Status.Replicas == replicas &&
+		deploy.Status.AvailableReplicas == replicas
+}
+
+// timedOut returns true if the drain started since more than drainTimeout
+func timedOut(start *metav1.Time) bool {
+	return start == nil || time.Since(start.Time) > drainTimeout
 }
 
 func (r *MailServerReconciler) reconcileARecord(ctx context.Context, s *mailv1alpha1.MailServer, res *resources.Resources) (ctrl.Result, bool, error) {
@@ -505,7 +1422,7 @@ func (r *MailServerReconciler) reconcileSPF(ctx context.Context, s *mailv1alpha1
 		return ctrl.Result{}, true, nil
 	}
 	spf := "v=spf1 a mx ip4:%s -all"
-	out, ok, err := r.execDeployOut(ctx, res.MailServer.Deployment, "curl ifconfig.me")
+	out, ok, err := r.execDeployOut(ctx, res.MailServer.Deployment, "curl", "-s", "ifconfig.me")
 	if !ok {
 		return ctrl.Result{}, false, err
 	}
@@ -539,7 +1456,7 @@ func (r *MailServerReconciler) reconcileDKIM(ctx context.Context, s *mailv1alpha
 	} else {
 		exists = true
 	}
-	out, ok, err := r.execDeployOut(ctx, res.MailServer.Deployment, fmt.Sprintf("cat /etc/opendkim/keys/%s/mail.txt", s.Spec.Domain))
+	out, ok, err := r.execDeployOut(ctx, res.MailServer.Deployment, "cat", path.Join("/tmp/docker-mailserver/opendkim/keys", s.Spec.Domain, "mail.txt"))
 	if err != nil {
 		log.Error(err, "unable to retrieve dkim key")
 		return ctrl.Result{}, false, err
@@ -584,31 +1501,123 @@ func (r *MailServerReconciler) reconcileDKIM(ctx context.Context, s *mailv1alpha
 	return ctrl.Result{}, false, nil
 }
 
+// owned is the list of the resource kinds owned by the MailServer
+var owned = []struct {
+	object client.Object
+	list   client.ObjectList
+}{
+	{object: &corev1.Secret{}, list: &corev1.SecretList{}},
+	{object: &appsv1.Deployment{}, list: &appsv1.DeploymentList{}},
+	{object: &corev1.Service{}, list: &corev1.ServiceList{}},
+	{object: &corev1.PersistentVolumeClaim{}, list: &corev1.PersistentVolumeClaimList{}},
+	{object: &batchv1.CronJob{}, list: &batchv1.CronJobList{}},
+	{object: &networkingv1.Ingress{}, list: &networkingv1.IngressList{}},
+	{object: &dnsv1alpha1.DNSRecord{}, list: &dnsv1alpha1.DNSRecordList{}},
+	{object: &cmv1.Certificate{}, list: &cmv1.CertificateList{}},
+	{object: &traefikv1alpha1.IngressRoute{}, list: &traefikv1alpha1.IngressRouteList{}},
+	{object: &traefikv1alpha1.Middleware{}, list: &traefikv1alpha1.MiddlewareList{}},
+}
+
+// mapReferencingMailServers returns the requests of the MailServers referencing the object with the given index key.
+// The Secrets are also mapped through the FetchmailSources using them as credentials.
+func (r *MailServerReconciler) mapReferencingMailServers(key string, fetchmail bool) handler.MapFunc {
+	return func(o client.Object) []reconcile.Request {
+		ctx := context.Background()
+		log := ctrl.LoggerFrom(ctx).WithValues("namespace", o.GetNamespace(), "name", o.GetName())
+		names := make(map[string]struct{})
+		var list mailv1alpha1.MailServerList
+		if err := r.List(ctx, &list, client.InNamespace(o.GetNamespace()), client.MatchingFields{key: o.GetName()}); err != nil {
+			log.Error(err, "unable to list referencing mail servers")
+			return nil
+		}
+		for _, v := range list.Items {
+			names[v.Name] = struct{}{}
+		}
+		if fetchmail {
+			var sources mailv1alpha1.FetchmailSourceList
+			if err := r.List(ctx, &sources, client.InNamespace(o.GetNamespace()), client.MatchingFields{fetchmailCredentialsKey: o.GetName()}); err != nil {
+				log.Error(err, "unable to list referencing fetchmail sources")
+				return nil
+			}
+			for _, v := range sources.Items {
+				names[v.Spec.MailServer] = struct{}{}
+			}
+		}
+		var requests []reconcile.Request
+		for v := range names {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: o.GetNamespace(), Name: v}})
+		}
+		return requests
+	}
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *MailServerReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	res := []client.Object{
-		&corev1.Secret{},
-		&appsv1.Deployment{},
-		&corev1.Service{},
-		&corev1.PersistentVolumeClaim{},
-		&networkingv1.Ingress{},
-		&dnsv1alpha1.DNSRecord{},
-		&cmv1.Certificate{},
-		&traefikv1alpha1.IngressRoute{},
-		&traefikv1alpha1.Middleware{},
-	}
 	c := ctrl.NewControllerManagedBy(mgr).
 		For(&mailv1alpha1.MailServer{})
-	for _, v := range res {
-		if err := mgr.GetFieldIndexer().IndexField(context.Background(), v, ownerKey, extractValue); err != nil {
+	for _, v := range owned {
+		if err := mgr.GetFieldIndexer().IndexField(context.Background(), v.object, ownerKey, extractValue); err != nil {
 			return err
 		}
-		c = c.Owns(v)
+		c = c.Owns(v.object)
 	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &mailv1alpha1.FetchmailSource{}, fetchmailMailServerKey, func(o client.Object) []string {
+		return []string{o.(*mailv1alpha1.FetchmailSource).Spec.MailServer}
+	}); err != nil {
+		return err
+	}
+	c = c.Watches(&source.Kind{Type: &mailv1alpha1.FetchmailSource{}}, handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: o.GetNamespace(), Name: o.(*mailv1alpha1.FetchmailSource).Spec.MailServer}}}
+	}))
+
+	indexes := []struct {
+		object client.Object
+		key    string
+		fn     client.IndexerFunc
+	}{
+		{object: &mailv1alpha1.FetchmailSource{}, key: fetchmailCredentialsKey, fn: func(o client.Object) []string {
+			return []string{o.(*mailv1alpha1.FetchmailSource).Spec.CredentialsSecret}
+		}},
+		{object: &mailv1alpha1.MailServer{}, key: secretRefsKey, fn: func(o client.Object) []string {
+			s := o.(*mailv1alpha1.MailServer)
+			// the certificate secret is managed by cert-manager, its renewal must roll the pods
+			return append(s.SecretRefs(), resources.Normalize(s.Spec.Domain, "tls"))
+		}},
+		{object: &mailv1alpha1.MailServer{}, key: configMapRefsKey, fn: func(o client.Object) []string {
+			return o.(*mailv1alpha1.MailServer).ConfigMapRefs()
+		}},
+	}
+	for _, v := range indexes {
+		if err := mgr.GetFieldIndexer().IndexField(context.Background(), v.object, v.key, v.fn); err != nil {
+			return err
+		}
+	}
+	c = c.Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.mapReferencingMailServers(secretRefsKey, true)))
+	c = c.Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.mapReferencingMailServers(configMapRefsKey, false)))
 
 	return c.Complete(r)
 }
 
+// samePorts checks that the services and deployments containers expose the same number of ports,
+// as DeepDerivative considers a shorter desired ports list as equal, e.g. when disabling POP3.
+func samePorts(v, got client.Object) bool {
+	switch v := v.(type) {
+	case *corev1.Service:
+		return len(v.Spec.Ports) == len(got.(*corev1.Service).Spec.Ports)
+	case *appsv1.Deployment:
+		containers := got.(*appsv1.Deployment).Spec.Template.Spec.Containers
+		if len(v.Spec.Template.Spec.Containers) != len(containers) {
+			return false
+		}
+		for i, c := range v.Spec.Template.Spec.Containers {
+			if len(c.Ports) != len(containers[i].Ports) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func extractValue(rawObj client.Object) []string {
 	// grab the owner object
 	owner := metav1.GetControllerOf(rawObj)
@@ -0,0 +1,111 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"go.linka.cloud/kube-mailserver/pkg/podexec"
+	"go.linka.cloud/kube-mailserver/pkg/resources"
+)
+
+func TestDrainQueue(t *testing.T) {
+	labels := map[string]string{"app": "mailserver"}
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "mail", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}},
+		},
+	}
+	pod := func(name string, phase corev1.PodPhase) client.Object {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	tests := []struct {
+		name string
+		pods []client.Object
+		// queues are the queue sizes printed by the drain command per pod name
+		queues map[string]string
+		count  int
+		ok     bool
+		err    bool
+	}{
+		{
+			name: "no running pod",
+			pods: []client.Object{pod("mail-0", corev1.PodPending)},
+		},
+		{
+			name:   "sum the running pods queues",
+			pods:   []client.Object{pod("mail-0", corev1.PodRunning), pod("mail-1", corev1.PodRunning), pod("mail-2", corev1.PodFailed)},
+			queues: map[string]string{"mail-0": "2\n", "mail-1": "3\n"},
+			count:  5,
+			ok:     true,
+		},
+		{
+			name:   "empty queue",
+			pods:   []client.Object{pod("mail-0", corev1.PodRunning)},
+			queues: map[string]string{"mail-0": "0\n"},
+			ok:     true,
+		},
+		{
+			name:   "unexpected output",
+			pods:   []client.Object{pod("mail-0", corev1.PodRunning)},
+			queues: map[string]string{"mail-0": "postqueue: fatal\n"},
+			err:    true,
+		},
+		{
+			name: "command failure",
+			pods: []client.Object{pod("mail-0", corev1.PodRunning)},
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &MailServerReconciler{
+				Client: fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(tt.pods...).Build(),
+				Exec: podexec.ExecutorFunc(func(ctx context.Context, pod *corev1.Pod, command []string, opts podexec.Options) error {
+					if want := podexec.Shell(resources.MailServerDrainCommand); !reflect.DeepEqual(command, want) {
+						return fmt.Errorf("unexpected command %q", command)
+					}
+					out, ok := tt.queues[pod.Name]
+					if !ok {
+						return &podexec.ExitError{Code: 1}
+					}
+					_, err := fmt.Fprint(opts.Stdout, out)
+					return err
+				}),
+			}
+			count, ok, err := r.drainQueue(context.Background(), deploy)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if count != tt.count || ok != tt.ok {
+				t.Fatalf("expected %d messages and %v, got %d and %v", tt.count, tt.ok, count, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+	"go.linka.cloud/kube-mailserver/pkg/resources"
+)
+
+const (
+	// LDAPReadyCondition reports whether the LDAP server is reachable with the bind credentials
+	// and the user filter matches entries under the search base
+	LDAPReadyCondition = "LDAPReady"
+
+	ldapTimeout = 10 * time.Second
+	// ldapRetryInterval is the delay before checking a failing LDAP server again
+	ldapRetryInterval = time.Minute
+)
+
+// ldapCheck is the LDAP preflight configuration
+type ldapCheck struct {
+	// Addr is the LDAP server host:port
+	Addr string
+	// Nameserver is the optional DNS server host:port resolving the LDAP server host, as in the mail server pods
+	Nameserver string
+	StartTLS   bool
+	TLS        *tls.Config
+	BindDN     string
+	BindPW     string
+	Base       string
+	// Filter is the user filter, its placeholders are replaced with a wildcard
+	Filter string
+}
+
+// ldapCheckError is a failed preflight step, its reason is reported in the condition
+type ldapCheckError struct {
+	Reason string
+	Err    error
+}
+
+func (e *ldapCheckError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ldapCheckError) Unwrap() error {
+	return e.Err
+}
+
+// checkLDAP dials the LDAP server, binds with the bind credentials and looks up a user with the user filter.
+// It only depends on its arguments so that it can run against any server, e.g. an in-process one.
+func checkLDAP(ctx context.Context, c ldapCheck) error {
+	scheme := "ldaps"
+	if c.StartTLS {
+		scheme = "ldap"
+	}
+	dialer := &net.Dialer{Timeout: ldapTimeout}
+	if c.Nameserver != "" {
+		dialer.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{Timeout: ldapTimeout}).DialContext(ctx, network, c.Nameserver)
+			},
+		}
+	}
+	conn, err := ldap.DialURL(
+		fmt.Sprintf("%s://%s", scheme, c.Addr),
+		ldap.DialWithDialer(dialer),
+		ldap.DialWithTLSConfig(c.TLS),
+	)
+	if err != nil {
+		return &ldapCheckError{Reason: "DialFailed", Err: err}
+	}
+	defer conn.Close()
+	conn.SetTimeout(ldapTimeout)
+	// the go-ldap requests do not take a context, close the connection to abort them
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	if c.StartTLS {
+		if err := conn.StartTLS(c.TLS); err != nil {
+			return &ldapCheckError{Reason: "StartTLSFailed", Err: err}
+		}
+	}
+	if err := conn.Bind(c.BindDN, c.BindPW); err != nil {
+		return &ldapCheckError{Reason: "BindFailed", Err: err}
+	}
+	filter := strings.NewReplacer("%s", "*", "%u", "*").Replace(c.Filter)
+	res, err := conn.Search(ldap.NewSearchRequest(c.Base, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 1, int(ldapTimeout.Seconds()), false, filter, []string{"dn"}, nil))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return &ldapCheckError{Reason: "SearchFailed", Err: err}
+	}
+	if res == nil || len(res.Entries) == 0 {
+		return &ldapCheckError{Reason: "NoUsers", Err: fmt.Errorf("no entry matches %s under %s", filter, c.Base)}
+	}
+	return nil
+}
+
+// reconcileLDAPReady runs the LDAP preflight and reports it in the LDAPReady condition.
+// The configuration is not rolled out while the check fails.
+// The result is kept for the MailServer generation and the bind secret version: a successful check is not run again
+// until one of them changes, a failing one is retried every ldapRetryInterval.
+func (r *MailServerReconciler) reconcileLDAPReady(ctx context.Context, s *mailv1alpha1.MailServer, bindDN, bindPW, bindVersion string) (ctrl.Result, bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	conditions := append([]metav1.Condition(nil), s.Status.Conditions...)
+	status := s.Status.LDAP
+	var checkErr error
+	if !s.Spec.Features.LDAP.Enabled {
+		meta.RemoveStatusCondition(&conditions, LDAPReadyCondition)
+		status = nil
+	} else {
+		if c, st := meta.FindStatusCondition(conditions, LDAPReadyCondition), s.Status.LDAP; c != nil && st != nil && st.LastCheckTime != nil &&
+			c.ObservedGeneration == s.Generation && st.BindSecretResourceVersion == bindVersion {
+			if c.Status == metav1.ConditionTrue {
+				return ctrl.Result{}, true, nil
+			}
+			if wait := time.Until(st.LastCheckTime.Add(ldapRetryInterval)); wait > 0 {
+				return ctrl.Result{RequeueAfter: wait}, false, nil
+			}
+		}
+		c, err := r.ldapCheck(ctx, s, bindDN, bindPW)
+		if err != nil {
+			log.Error(err, "unable to build LDAP preflight configuration")
+			return ctrl.Result{}, false, err
+		}
+		condition := metav1.Condition{
+			Type:               LDAPReadyCondition,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: s.Generation,
+			Reason:             "Ready",
+			Message:            "LDAP server reachable and user filter matching",
+		}
+		if checkErr = checkLDAP(ctx, c); checkErr != nil {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "Failed"
+			var e *ldapCheckError
+			if errors.As(checkErr, &e) {
+				condition.Reason = e.Reason
+			}
+			condition.Message = checkErr.Error()
+		}
+		meta.SetStatusCondition(&conditions, condition)
+		status = &mailv1alpha1.LDAPStatus{
+			BindSecretResourceVersion: bindVersion,
+			LastCheckTime:             &metav1.Time{Time: time.Now()},
+		}
+	}
+	if !equality.Semantic.DeepEqual(conditions, s.Status.Conditions) || !equality.Semantic.DeepEqual(status, s.Status.LDAP) {
+		s.Status.Conditions = conditions
+		s.Status.LDAP = status
+		if err := r.Status().Update(ctx, s); err != nil {
+			log.Error(err, "unable to update LDAP condition")
+			return ctrl.Result{}, false, err
+		}
+	}
+	if checkErr != nil {
+		log.Info("LDAP preflight failed, not rolling out the configuration", "error", checkErr.Error())
+		return ctrl.Result{RequeueAfter: ldapRetryInterval}, false, nil
+	}
+	return ctrl.Result{}, true, nil
+}
+
+// ldapCheck builds the preflight configuration from the MailServer LDAP settings
+func (r *MailServerReconciler) ldapCheck(ctx context.Context, s *mailv1alpha1.MailServer, bindDN, bindPW string) (ldapCheck, error) {
+	l := s.Spec.Features.LDAP
+	port := l.Port
+	if port == 0 {
+		port = 636
+		if l.StartTLS {
+			port = 389
+		}
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         l.Host,
+		InsecureSkipVerify: l.TLS.InsecureSkipVerify,
+	}
+	if ca := l.TLS.CA; ca != nil {
+		var bundle []byte
+		switch {
+		case ca.Secret != nil:
+			var secret corev1.Secret
+			if err := r.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: ca.Secret.Name}, &secret); err != nil {
+				return ldapCheck{}, err
+			}
+			bundle = secret.Data[ca.Secret.Key]
+		case ca.ConfigMap != nil:
+			var cm corev1.ConfigMap
+			if err := r.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: ca.ConfigMap.Name}, &cm); err != nil {
+				return ldapCheck{}, err
+			}
+			bundle = []byte(cm.Data[ca.ConfigMap.Key])
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(bundle) {
+			return ldapCheck{}, fmt.Errorf("no certificate found in the LDAP CA bundle")
+		}
+	}
+	if l.TLS.ClientCertSecret != "" {
+		var secret corev1.Secret
+		if err := r.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: l.TLS.ClientCertSecret}, &secret); err != nil {
+			return ldapCheck{}, err
+		}
+		cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+		if err != nil {
+			return ldapCheck{}, fmt.Errorf("invalid LDAP client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	var nameserver string
+	// the mail server pods resolve the LDAP server through the nameserver
+	if l.Nameserver != nil {
+		nameserver = net.JoinHostPort(string(*l.Nameserver), "53")
+	}
+	return ldapCheck{
+		Addr:       net.JoinHostPort(l.Host, strconv.Itoa(port)),
+		Nameserver: nameserver,
+		StartTLS:   l.StartTLS,
+		TLS:        tlsConfig,
+		BindDN:     bindDN,
+		BindPW:     bindPW,
+		Base:       l.SearchBase,
+		Filter:     resources.LDAPFilters(l).User,
+	}, nil
+}
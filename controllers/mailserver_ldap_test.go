@@ -0,0 +1,281 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/miekg/dns"
+)
+
+// ldapTestHost is the LDAP server host name only resolved by the test nameserver
+const ldapTestHost = "ldap.kube-mailserver.test"
+
+// ldapTestNameserver starts a DNS server resolving ldapTestHost to 127.0.0.1 and returns its address
+func ldapTestNameserver(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := dns.NewServeMux()
+	mux.HandleFunc(dns.Fqdn(ldapTestHost), func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		if req.Question[0].Qtype == dns.TypeA {
+			m.Answer = append(m.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   net.IPv4(127, 0, 0, 1),
+			})
+		}
+		w.WriteMsg(m)
+	})
+	srv := &dns.Server{PacketConn: pc, Handler: mux}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() {
+		srv.Shutdown()
+	})
+	return pc.LocalAddr().String()
+}
+
+// ldapTestServer is a minimal in-process LDAP server answering the preflight requests
+type ldapTestServer struct {
+	ln  net.Listener
+	tls *tls.Config
+	// startTLS accepts the StartTLS extended request, the listener is plain text when set
+	startTLS bool
+	bindDN   string
+	bindPW   string
+	base     string
+	users    []string
+}
+
+func (s *ldapTestServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *ldapTestServer) handle(conn net.Conn) {
+	// conn is replaced by the TLS connection after StartTLS
+	defer func() {
+		conn.Close()
+	}()
+	for {
+		p, err := ber.ReadPacket(conn)
+		if err != nil || len(p.Children) < 2 {
+			return
+		}
+		id := p.Children[0].Value.(int64)
+		op := p.Children[1]
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			code := uint16(ldap.LDAPResultInvalidCredentials)
+			if len(op.Children) == 3 && op.Children[1].Data.String() == s.bindDN && op.Children[2].Data.String() == s.bindPW {
+				code = ldap.LDAPResultSuccess
+			}
+			conn.Write(ldapTestResponse(id, ldap.ApplicationBindResponse, code).Bytes())
+		case ldap.ApplicationExtendedRequest:
+			if !s.startTLS {
+				conn.Write(ldapTestResponse(id, ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError).Bytes())
+				continue
+			}
+			conn.Write(ldapTestResponse(id, ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess).Bytes())
+			conn = tls.Server(conn, s.tls)
+		case ldap.ApplicationSearchRequest:
+			if op.Children[0].Data.String() == s.base {
+				for _, v := range s.users {
+					conn.Write(ldapTestEntry(id, v).Bytes())
+				}
+			}
+			conn.Write(ldapTestResponse(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess).Bytes())
+		default:
+			return
+		}
+	}
+}
+
+func ldapTestMessage(id int64, op *ber.Packet) *ber.Packet {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	p.AppendChild(op)
+	return p
+}
+
+func ldapTestResponse(id int64, tag ber.Tag, code uint16) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ldap.LDAPResultCodeMap[code], "Diagnostic Message"))
+	return ldapTestMessage(id, op)
+}
+
+func ldapTestEntry(id int64, dn string) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "Object Name"))
+	op.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes"))
+	return ldapTestMessage(id, op)
+}
+
+// ldapTestTLS returns a self-signed server configuration for 127.0.0.1 and the client configuration trusting it
+func ldapTestTLS(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldap"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client = &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+	return server, client
+}
+
+func TestCheckLDAP(t *testing.T) {
+	serverTLS, clientTLS := ldapTestTLS(t)
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	tests := []struct {
+		name string
+		// server is nil when nothing listens
+		server *ldapTestServer
+		check  ldapCheck
+		// resolve dials the server by ldapTestHost through the test nameserver
+		resolve bool
+		reason  string
+	}{
+		{
+			name:   "dial failure",
+			check:  ldapCheck{Addr: closed.Addr().String()},
+			reason: "DialFailed",
+		},
+		{
+			name:   "starttls failure",
+			server: &ldapTestServer{bindDN: "cn=admin", bindPW: "secret", base: "dc=example", users: []string{"uid=user,dc=example"}},
+			check:  ldapCheck{StartTLS: true, BindDN: "cn=admin", BindPW: "secret", Base: "dc=example", Filter: "(uid=%u)"},
+			reason: "StartTLSFailed",
+		},
+		{
+			name:   "bind failure",
+			server: &ldapTestServer{bindDN: "cn=admin", bindPW: "secret", base: "dc=example", users: []string{"uid=user,dc=example"}},
+			check:  ldapCheck{BindDN: "cn=admin", BindPW: "wrong", Base: "dc=example", Filter: "(uid=%u)"},
+			reason: "BindFailed",
+		},
+		{
+			name:   "no match",
+			server: &ldapTestServer{bindDN: "cn=admin", bindPW: "secret", base: "dc=example"},
+			check:  ldapCheck{BindDN: "cn=admin", BindPW: "secret", Base: "dc=example", Filter: "(uid=%u)"},
+			reason: "NoUsers",
+		},
+		{
+			name:   "ldaps success",
+			server: &ldapTestServer{bindDN: "cn=admin", bindPW: "secret", base: "dc=example", users: []string{"uid=user,dc=example"}},
+			check:  ldapCheck{BindDN: "cn=admin", BindPW: "secret", Base: "dc=example", Filter: "(uid=%u)"},
+		},
+		{
+			name:   "starttls success",
+			server: &ldapTestServer{startTLS: true, bindDN: "cn=admin", bindPW: "secret", base: "dc=example", users: []string{"uid=user,dc=example"}},
+			check:  ldapCheck{StartTLS: true, BindDN: "cn=admin", BindPW: "secret", Base: "dc=example", Filter: "(uid=%u)"},
+		},
+		{
+			name:    "nameserver resolution",
+			server:  &ldapTestServer{bindDN: "cn=admin", bindPW: "secret", base: "dc=example", users: []string{"uid=user,dc=example"}},
+			check:   ldapCheck{BindDN: "cn=admin", BindPW: "secret", Base: "dc=example", Filter: "(uid=%u)"},
+			resolve: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.check
+			c.TLS = clientTLS
+			if s := tt.server; s != nil {
+				s.tls = serverTLS
+				ln, err := net.Listen("tcp", "127.0.0.1:0")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer ln.Close()
+				s.ln = ln
+				// without StartTLS the client dials ldaps
+				if !c.StartTLS {
+					s.ln = tls.NewListener(ln, serverTLS)
+				}
+				go s.serve()
+				c.Addr = ln.Addr().String()
+				if tt.resolve {
+					_, port, _ := net.SplitHostPort(c.Addr)
+					c.Addr = net.JoinHostPort(ldapTestHost, port)
+					c.Nameserver = ldapTestNameserver(t)
+				}
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			err := checkLDAP(ctx, c)
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var e *ldapCheckError
+			if !errors.As(err, &e) {
+				t.Fatalf("expected a %s error, got %v", tt.reason, err)
+			}
+			if e.Reason != tt.reason {
+				t.Fatalf("expected reason %s, got %s: %v", tt.reason, e.Reason, e.Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	dnsv1alpha1 "go.linka.cloud/k8s/dns/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+	"go.linka.cloud/kube-mailserver/pkg/resources"
+)
+
+const metricsNamespace = "kube_mailserver"
+
+var (
+	reconcileStepDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_step_duration_seconds",
+		Help:      "Duration of the MailServer reconciliation steps.",
+	}, []string{"namespace", "name", "step"})
+	reconcileStepErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_step_errors_total",
+		Help:      "Number of errors returned by the MailServer reconciliation steps.",
+	}, []string{"namespace", "name", "step"})
+	restarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "restarts_total",
+		Help:      "Number of mail server restarts triggered by configuration changes.",
+	}, []string{"namespace", "name"})
+	certificateExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "certificate_expiry_timestamp_seconds",
+		Help:      "Expiry time of the MailServer certificates in seconds since the epoch.",
+	}, []string{"namespace", "name", "certificate"})
+	dnsRecordReady = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "dns_record_ready",
+		Help:      "Whether the MailServer DNS record has been applied by the DNS provider.",
+	}, []string{"namespace", "name", "record"})
+	ready = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "ready",
+		Help:      "Whether all the mail server replicas are updated and available.",
+	}, []string{"namespace", "name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		reconcileStepDuration,
+		reconcileStepErrors,
+		restarts,
+		certificateExpiry,
+		dnsRecordReady,
+		ready,
+	)
+}
+
+// reconcileStep is a reconciliation step, it returns false if the reconciliation must stop
+type reconcileStep func(ctx context.Context, s *mailv1alpha1.MailServer, res *resources.Resources) (ctrl.Result, bool, error)
+
+// observeStep runs the step and records its duration and error
+func observeStep(ctx context.Context, name string, s *mailv1alpha1.MailServer, res *resources.Resources, step reconcileStep) (ctrl.Result, bool, error) {
+	start := time.Now()
+	result, ok, err := step(ctx, s, res)
+	reconcileStepDuration.WithLabelValues(s.Namespace, s.Name, name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		reconcileStepErrors.WithLabelValues(s.Namespace, s.Name, name).Inc()
+	}
+	return result, ok, err
+}
+
+// recordMetrics records the certificates expiry, the DNS records and the mail server readiness.
+// The metrics are best effort, the errors are only logged.
+func (r *MailServerReconciler) recordMetrics(ctx context.Context, s *mailv1alpha1.MailServer, res *resources.Resources) {
+	log := ctrl.LoggerFrom(ctx)
+	labels := prometheus.Labels{"namespace": s.Namespace, "name": s.Name}
+	// the disabled features resources are not reported anymore
+	certificateExpiry.DeletePartialMatch(labels)
+	dnsRecordReady.DeletePartialMatch(labels)
+	for _, v := range res.Owned() {
+		switch v := v.(type) {
+		case *cmv1.Certificate:
+			var cert cmv1.Certificate
+			if err := r.Get(ctx, client.ObjectKeyFromObject(v), &cert); err != nil {
+				log.V(5).Info("unable to fetch certificate", "name", v.Name, "error", err)
+				continue
+			}
+			if cert.Status.NotAfter != nil {
+				certificateExpiry.WithLabelValues(s.Namespace, s.Name, v.Name).Set(float64(cert.Status.NotAfter.Unix()))
+			}
+		case *dnsv1alpha1.DNSRecord:
+			var rec dnsv1alpha1.DNSRecord
+			if err := client.IgnoreNotFound(r.Get(ctx, client.ObjectKeyFromObject(v), &rec)); err != nil {
+				log.V(5).Info("unable to fetch dns record", "name", v.Name, "error", err)
+				continue
+			}
+			dnsRecordReady.WithLabelValues(s.Namespace, s.Name, v.Name).Set(boolValue(rec.Status.Record != ""))
+		}
+	}
+	var deploy appsv1.Deployment
+	if err := r.Get(ctx, client.ObjectKeyFromObject(res.MailServer.Deployment), &deploy); err != nil {
+		log.V(5).Info("unable to fetch mail server deployment", "error", err)
+		ready.WithLabelValues(s.Namespace, s.Name).Set(0)
+		return
+	}
+	ready.WithLabelValues(s.Namespace, s.Name).Set(boolValue(deploy.Status.ReadyReplicas != 0 && rolledOut(&deploy)))
+}
+
+// deleteMetrics removes the metrics of the deleted MailServer
+func deleteMetrics(namespace, name string) {
+	labels := prometheus.Labels{"namespace": namespace, "name": name}
+	for _, v := range []interface {
+		DeletePartialMatch(prometheus.Labels) int
+	}{reconcileStepDuration, reconcileStepErrors, restarts, certificateExpiry, dnsRecordReady, ready} {
+		v.DeletePartialMatch(labels)
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -23,7 +23,6 @@ import (
 	traefikv1alpha1 "github.com/traefik/traefik/v2/pkg/provider/kubernetes/crd/traefik/v1alpha1"
 	"go.linka.cloud/grpc/logger"
 	dnsv1alpha1 "go.linka.cloud/k8s/dns/api/v1alpha1"
-	"k8s.io/client-go/kubernetes"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -37,6 +36,7 @@ import (
 
 	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
 	"go.linka.cloud/kube-mailserver/controllers"
+	"go.linka.cloud/kube-mailserver/pkg/podexec"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -75,7 +75,11 @@ func Main() {
 
 	restConfig := ctrl.GetConfigOrDie()
 
-	goClient := kubernetes.NewForConfigOrDie(restConfig)
+	exec, err := podexec.New(restConfig)
+	if err != nil {
+		setupLog.Error(err, "unable to create pod executor")
+		os.Exit(1)
+	}
 
 	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                 scheme,
@@ -102,14 +106,21 @@ func Main() {
 	}
 
 	if err = (&controllers.MailServerReconciler{
-		Client:     mgr.GetClient(),
-		Scheme:     mgr.GetScheme(),
-		RestConfig: restConfig,
-		GoClient:   goClient,
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Exec:   exec,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MailServer")
 		os.Exit(1)
 	}
+	if err = (&controllers.FetchmailSourceReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Exec:   exec,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "FetchmailSource")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
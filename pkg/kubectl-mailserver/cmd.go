@@ -19,17 +19,19 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"golang.org/x/term"
 	appsv1 "k8s.io/api/apps/v1"
-	corev1 "k8s.io/api/core/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes/scheme"
 	client2 "sigs.k8s.io/controller-runtime/pkg/client"
 
 	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+	"go.linka.cloud/kube-mailserver/pkg/podexec"
 	"go.linka.cloud/kube-mailserver/pkg/resources"
 )
 
@@ -38,9 +40,14 @@ var (
 	resourceFlags = genericclioptions.NewResourceBuilderFlags().WithAllNamespaces(true)
 	client        client2.Client
 	ns            string
+	output        string
 	RootCmd       = cobra.Command{
-		Use:           "mailserver [instance]",
-		Short:         "mailserver setup command",
+		Use:   "mailserver [instance] [setup args...]",
+		Short: "mailserver setup command",
+		Long: `Run the docker-mailserver setup command in a mail server pod.
+
+The mail queue of all the replicas is managed with:
+  kubectl mailserver [instance] ` + queueUsage,
 		Args:          cobra.MinimumNArgs(1),
 		SilenceUsage:  true,
 		SilenceErrors: true,
@@ -69,25 +76,40 @@ var (
 			if err := client.Get(ctx, client2.ObjectKey{Namespace: ns, Name: resources.Normalize("mail", ms.Spec.Domain)}, &deploy); err != nil {
 				return err
 			}
-			var pods corev1.PodList
-			if err := client.List(ctx, &pods, client2.InNamespace(ns), client2.MatchingLabels(deploy.Spec.Template.Labels)); err != nil {
+			exec, err := podexec.New(conf)
+			if err != nil {
 				return err
 			}
-			if len(pods.Items) == 0 {
-				return fmt.Errorf("no pods found")
-			}
-			var p *corev1.Pod
-			for _, v := range pods.Items {
-				if v.Status.Phase == corev1.PodRunning {
-					p = &v
-					break
+			if len(args) > 1 && args[1] == "queue" {
+				// the draining replicas are not ready but still hold messages
+				pods, err := podexec.RunningPods(ctx, client, ns, deploy.Spec.Template.Labels)
+				if err != nil {
+					return err
 				}
+				return runQueue(ctx, cmd.OutOrStdout(), exec, pods, output, args[2:])
+			}
+			p, err := podexec.ReadyPod(ctx, client, ns, deploy.Spec.Template.Labels)
+			if err != nil {
+				return err
 			}
 			if p == nil {
-				return fmt.Errorf("no running pods found")
+				return fmt.Errorf("no ready pods found")
+			}
+			opts := podexec.Options{
+				Stdin:  cmd.InOrStdin(),
+				Stdout: &replacer{w: cmd.OutOrStdout()},
+				Stderr: &replacer{w: cmd.ErrOrStderr()},
+			}
+			// only allocate a terminal for interactive sessions, e.g. to type a password
+			if f, ok := cmd.InOrStdin().(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+				state, err := term.MakeRaw(int(f.Fd()))
+				if err != nil {
+					return err
+				}
+				defer term.Restore(int(f.Fd()), state)
+				opts.TTY = true
 			}
-			stderr, stdout := replacer{w: cmd.ErrOrStderr()}, replacer{w: cmd.OutOrStdout()}
-			if err := Exec(ctx, conf, p, append([]string{"setup"}, args[1:]...), cmd.InOrStdin(), &stdout, &stderr); err != nil {
+			if err := exec.Exec(ctx, p, append([]string{"setup"}, args[1:]...), opts); err != nil {
 				return err
 			}
 			return nil
@@ -112,6 +134,7 @@ func init() {
 	utilruntime.Must(mailv1alpha1.AddToScheme(scheme.Scheme))
 	flags := pflag.NewFlagSet("kubectl-mailserver", pflag.ExitOnError)
 	pflag.CommandLine = flags
+	RootCmd.Flags().StringVarP(&output, "output", "o", "table", "Output format of the queue commands: table or json")
 	configFlags.AddFlags(RootCmd.Flags())
 	resourceFlags.AddFlags(RootCmd.Flags())
 }
@@ -1,76 +0,0 @@
-// Copyright 2022 Linka Cloud  All rights reserved.
-//
-// Licensed under the Apache License, Version 2.0 (the "License");
-// you may not use this file except in compliance with the License.
-// You may obtain a copy of the License at
-//
-//     http://www.apache.org/licenses/LICENSE-2.0
-//
-// Unless required by applicable law or agreed to in writing, software
-// distributed under the License is distributed on an "AS IS" BASIS,
-// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-// See the License for the specific language governing permissions and
-// limitations under the License.
-
-package kubectl_mailserver
-
-import (
-	"context"
-	"io"
-	"net/http"
-	"strings"
-
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/remotecommand"
-)
-
-func Exec(ctx context.Context, restConfig *rest.Config, pod *corev1.Pod, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
-	// Create a clientset
-	clientset, err := kubernetes.NewForConfig(restConfig)
-	if err != nil {
-		return err
-	}
-
-	cmd := []string{
-		"sh",
-		"-c",
-		strings.Join(command, " "),
-	}
-
-	req := clientset.CoreV1().
-		RESTClient().
-		Post().
-		Resource("pods").
-		Namespace(pod.Namespace).
-		Name(pod.Name).
-		SubResource("exec")
-	option := &corev1.PodExecOptions{
-		Command: cmd,
-		Stdin:   true,
-		Stdout:  true,
-		Stderr:  true,
-		TTY:     true,
-	}
-	if stdin == nil {
-		option.Stdin = false
-	}
-	req.VersionedParams(
-		option,
-		scheme.ParameterCodec,
-	)
-	exec, err := remotecommand.NewSPDYExecutor(restConfig, http.MethodPost, req.URL())
-	if err != nil {
-		return err
-	}
-	if err = exec.Stream(remotecommand.StreamOptions{
-		Stdin:  stdin,
-		Stdout: stdout,
-		Stderr: stderr,
-	}); err != nil {
-		return err
-	}
-	return nil
-}
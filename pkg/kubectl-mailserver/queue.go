@@ -0,0 +1,229 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubectl_mailserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"go.linka.cloud/kube-mailserver/pkg/podexec"
+)
+
+const queueUsage = "queue list|show <id>|flush|hold <id>...|release <id>...|delete <id>..."
+
+// queueIDPattern matches the Postfix short and long queue ids, and the postsuper ALL keyword
+var queueIDPattern = regexp.MustCompile(`^[0-9A-Za-z]+$`)
+
+// queueActions are the postsuper flags of the queue management commands
+var queueActions = map[string]string{
+	"hold":    "-h",
+	"release": "-H",
+	"delete":  "-d",
+}
+
+type queueMessage struct {
+	Pod         string           `json:"pod"`
+	Queue       string           `json:"queue_name"`
+	ID          string           `json:"queue_id"`
+	ArrivalTime int64            `json:"arrival_time"`
+	Size        int64            `json:"message_size"`
+	Sender      string           `json:"sender"`
+	Recipients  []queueRecipient `json:"recipients"`
+}
+
+type queueRecipient struct {
+	Address     string `json:"address"`
+	DelayReason string `json:"delay_reason,omitempty"`
+}
+
+type queueContent struct {
+	Pod     string `json:"pod"`
+	ID      string `json:"queue_id"`
+	Content string `json:"content"`
+}
+
+type queueResult struct {
+	Pod    string   `json:"pod"`
+	Action string   `json:"action"`
+	IDs    []string `json:"queue_ids,omitempty"`
+}
+
+// runQueue runs the queue command on all the mail server pods, as each replica has its own Postfix queue
+func runQueue(ctx context.Context, w io.Writer, exec podexec.Executor, pods []corev1.Pod, output string, args []string) error {
+	if output != "table" && output != "json" {
+		return fmt.Errorf("unsupported output format %q: must be table or json", output)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("missing queue command: %s", queueUsage)
+	}
+	if len(pods) == 0 {
+		return errors.New("no running pods found")
+	}
+	ids := args[1:]
+	for _, v := range ids {
+		if !queueIDPattern.MatchString(v) {
+			return fmt.Errorf("invalid queue id %q", v)
+		}
+	}
+	switch args[0] {
+	case "list":
+		if len(ids) != 0 {
+			return fmt.Errorf("usage: %s", queueUsage)
+		}
+		return queueList(ctx, w, exec, pods, output)
+	case "show":
+		if len(ids) != 1 {
+			return fmt.Errorf("usage: queue show <id>")
+		}
+		return queueShow(ctx, w, exec, pods, output, ids[0])
+	case "flush":
+		if len(ids) != 0 {
+			return fmt.Errorf("usage: %s", queueUsage)
+		}
+		var results []queueResult
+		for i := range pods {
+			if _, err := podexec.Output(ctx, exec, &pods[i], "postqueue", "-f"); err != nil {
+				return fmt.Errorf("%s: %w", pods[i].Name, err)
+			}
+			results = append(results, queueResult{Pod: pods[i].Name, Action: "flush"})
+		}
+		return printQueueResults(w, output, results)
+	case "hold", "release", "delete":
+		if len(ids) == 0 {
+			return fmt.Errorf("usage: queue %s <id>... (ALL for all the messages)", args[0])
+		}
+		command := []string{"postsuper"}
+		for _, v := range ids {
+			command = append(command, queueActions[args[0]], v)
+		}
+		var results []queueResult
+		for i := range pods {
+			if _, err := podexec.Output(ctx, exec, &pods[i], command...); err != nil {
+				return fmt.Errorf("%s: %w", pods[i].Name, err)
+			}
+			results = append(results, queueResult{Pod: pods[i].Name, Action: args[0], IDs: ids})
+		}
+		return printQueueResults(w, output, results)
+	default:
+		return fmt.Errorf("unknown queue command %q: %s", args[0], queueUsage)
+	}
+}
+
+func queueList(ctx context.Context, w io.Writer, exec podexec.Executor, pods []corev1.Pod, output string) error {
+	messages := []queueMessage{}
+	for i := range pods {
+		out, err := podexec.Output(ctx, exec, &pods[i], "postqueue", "-j")
+		if err != nil {
+			return fmt.Errorf("%s: %w", pods[i].Name, err)
+		}
+		// postqueue prints one JSON object per message
+		s := bufio.NewScanner(strings.NewReader(out))
+		s.Buffer(nil, 1024*1024)
+		for s.Scan() {
+			if strings.TrimSpace(s.Text()) == "" {
+				continue
+			}
+			m := queueMessage{Pod: pods[i].Name}
+			if err := json.Unmarshal(s.Bytes(), &m); err != nil {
+				return fmt.Errorf("%s: decode postqueue output: %w", pods[i].Name, err)
+			}
+			messages = append(messages, m)
+		}
+		if err := s.Err(); err != nil {
+			return err
+		}
+	}
+	if output == "json" {
+		return printJSON(w, messages)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "POD\tQUEUE\tID\tARRIVAL\tSIZE\tSENDER\tRECIPIENTS\tREASON")
+	for _, v := range messages {
+		var recipients []string
+		var reason string
+		for _, r := range v.Recipients {
+			recipients = append(recipients, r.Address)
+			if reason == "" {
+				reason = r.DelayReason
+			}
+		}
+		sender := v.Sender
+		if sender == "" {
+			sender = "<>"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", v.Pod, v.Queue, v.ID, time.Unix(v.ArrivalTime, 0).UTC().Format(time.RFC3339), v.Size, sender, strings.Join(recipients, ","), truncate(reason, 60))
+	}
+	return tw.Flush()
+}
+
+// queueShow prints the message from the first pod holding it
+func queueShow(ctx context.Context, w io.Writer, exec podexec.Executor, pods []corev1.Pod, output, id string) error {
+	for i := range pods {
+		out, err := podexec.Output(ctx, exec, &pods[i], "postcat", "-q", id)
+		if err != nil {
+			var exitErr *podexec.ExitError
+			if errors.As(err, &exitErr) {
+				// the message is not in this replica queue
+				continue
+			}
+			return fmt.Errorf("%s: %w", pods[i].Name, err)
+		}
+		if output == "json" {
+			return printJSON(w, queueContent{Pod: pods[i].Name, ID: id, Content: out})
+		}
+		_, err = io.WriteString(w, out)
+		return err
+	}
+	return fmt.Errorf("message %s not found", id)
+}
+
+func printQueueResults(w io.Writer, output string, results []queueResult) error {
+	if output == "json" {
+		return printJSON(w, results)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "POD\tACTION\tMESSAGES")
+	for _, v := range results {
+		ids := strings.Join(v.IDs, ",")
+		if ids == "" {
+			ids = "ALL"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", v.Pod, v.Action, ids)
+	}
+	return tw.Flush()
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
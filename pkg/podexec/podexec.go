@@ -0,0 +1,222 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package podexec runs commands in the pods containers through the API server exec subresource
+package podexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// DefaultContainerAnnotation selects the container used when none is given, as kubectl does
+const DefaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// Options are the command streams and terminal options
+type Options struct {
+	// Container is the container to run the command in,
+	// it defaults to the DefaultContainerAnnotation one or to the first container
+	Container string
+	Stdin     io.Reader
+	Stdout    io.Writer
+	Stderr    io.Writer
+	// TTY allocates a terminal, it must only be used for interactive sessions
+	// as the terminal merges the standard error in the standard output and adds carriage returns
+	TTY bool
+}
+
+// Executor runs commands in the pods
+type Executor interface {
+	// Exec runs the command argv in the pod without any shell interpretation.
+	// It returns an *ExitError if the command exits with a non-zero code,
+	// and the context error if the context is done before the command exits.
+	Exec(ctx context.Context, pod *corev1.Pod, command []string, opts Options) error
+}
+
+// ExecutorFunc adapts a function to the Executor interface, e.g. to fake the executions in tests
+type ExecutorFunc func(ctx context.Context, pod *corev1.Pod, command []string, opts Options) error
+
+func (f ExecutorFunc) Exec(ctx context.Context, pod *corev1.Pod, command []string, opts Options) error {
+	return f(ctx, pod, command, opts)
+}
+
+// ExitError is returned when the command exits with a non-zero code
+type ExitError struct {
+	Code int
+	// Stderr is the command standard error, only set by Output
+	Stderr string
+}
+
+func (e *ExitError) Error() string {
+	if e.Stderr != "" {
+		return fmt.Sprintf("command terminated with exit code %d: %s", e.Code, e.Stderr)
+	}
+	return fmt.Sprintf("command terminated with exit code %d", e.Code)
+}
+
+// New returns an Executor using the SPDY protocol
+func New(config *rest.Config) (Executor, error) {
+	c, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &executor{client: c.CoreV1().RESTClient(), config: config}, nil
+}
+
+type executor struct {
+	client rest.Interface
+	config *rest.Config
+}
+
+func (e *executor) Exec(ctx context.Context, pod *corev1.Pod, command []string, opts Options) error {
+	if len(command) == 0 {
+		return errors.New("empty command")
+	}
+	if opts.Container == "" {
+		opts.Container = defaultContainer(pod)
+	}
+	if opts.TTY {
+		opts.Stderr = nil
+	}
+	req := e.client.Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: opts.Container,
+			Command:   command,
+			Stdin:     opts.Stdin != nil,
+			Stdout:    opts.Stdout != nil,
+			Stderr:    opts.Stderr != nil,
+			TTY:       opts.TTY,
+		}, scheme.ParameterCodec)
+	exec, err := remotecommand.NewSPDYExecutor(e.config, http.MethodPost, req.URL())
+	if err != nil {
+		return err
+	}
+	// the stream cannot be interrupted before client-go v0.26, it is abandoned when the context is done:
+	// the writers are detached so that the caller buffers are not written anymore
+	stdout, stderr := detachable(opts.Stdout), detachable(opts.Stderr)
+	done := make(chan error, 1)
+	go func() {
+		done <- exec.Stream(remotecommand.StreamOptions{
+			Stdin:  opts.Stdin,
+			Stdout: stdout.writer(),
+			Stderr: stderr.writer(),
+			Tty:    opts.TTY,
+		})
+	}()
+	select {
+	case err := <-done:
+		var exitErr utilexec.ExitError
+		if errors.As(err, &exitErr) && exitErr.Exited() {
+			return &ExitError{Code: exitErr.ExitStatus()}
+		}
+		return err
+	case <-ctx.Done():
+		stdout.detach()
+		stderr.detach()
+		return ctx.Err()
+	}
+}
+
+// Output runs the command and returns its standard output, the standard error is reported in the error
+func Output(ctx context.Context, e Executor, pod *corev1.Pod, command ...string) (string, error) {
+	return OutputStdin(ctx, e, pod, nil, command...)
+}
+
+// OutputStdin runs the command with the given standard input and returns its standard output,
+// e.g. to pass a secret that must not appear in the command line
+func OutputStdin(ctx context.Context, e Executor, pod *corev1.Pod, stdin io.Reader, command ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	err := e.Exec(ctx, pod, command, Options{Stdin: stdin, Stdout: &stdout, Stderr: &stderr})
+	var exitErr *ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		exitErr.Stderr = strings.TrimSpace(stderr.String())
+	case err != nil && stderr.Len() != 0:
+		err = fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), err
+}
+
+// Shell returns the argv running the script with sh, the arguments are passed as the $1, $2... positional parameters
+// so that they never need to be quoted
+func Shell(script string, args ...string) []string {
+	return append([]string{"sh", "-c", script, "sh"}, args...)
+}
+
+func defaultContainer(pod *corev1.Pod) string {
+	if v := pod.Annotations[DefaultContainerAnnotation]; v != "" {
+		return v
+	}
+	if len(pod.Spec.Containers) != 0 {
+		return pod.Spec.Containers[0].Name
+	}
+	return ""
+}
+
+// detachableWriter discards the writes once detached
+type detachableWriter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	detached bool
+}
+
+func detachable(w io.Writer) *detachableWriter {
+	if w == nil {
+		return nil
+	}
+	return &detachableWriter{w: w}
+}
+
+// writer returns the writer as an io.Writer, keeping the nil streams nil
+func (d *detachableWriter) writer() io.Writer {
+	if d == nil {
+		return nil
+	}
+	return d
+}
+
+func (d *detachableWriter) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.detached {
+		return len(p), nil
+	}
+	return d.w.Write(p)
+}
+
+func (d *detachableWriter) detach() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.detached = true
+	d.mu.Unlock()
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		exec   ExecutorFunc
+		stdin  string
+		out    string
+		code   int
+		errMsg string
+	}{
+		{
+			name: "success",
+			exec: func(ctx context.Context, pod *corev1.Pod, command []string, opts Options) error {
+				fmt.Fprint(opts.Stdout, "ok\n")
+				return nil
+			},
+			out: "ok\n",
+		},
+		{
+			name: "stdin",
+			exec: func(ctx context.Context, pod *corev1.Pod, command []string, opts Options) error {
+				_, err := io.Copy(opts.Stdout, opts.Stdin)
+				return err
+			},
+			stdin: "secret\n",
+			out:   "secret\n",
+		},
+		{
+			name: "exit error",
+			exec: func(ctx context.Context, pod *corev1.Pod, command []string, opts Options) error {
+				fmt.Fprint(opts.Stdout, "partial")
+				fmt.Fprint(opts.Stderr, "  postqueue: fatal: no queue\n")
+				return &ExitError{Code: 75}
+			},
+			out:    "partial",
+			code:   75,
+			errMsg: "command terminated with exit code 75: postqueue: fatal: no queue",
+		},
+		{
+			name: "stream error",
+			exec: func(ctx context.Context, pod *corev1.Pod, command []string, opts Options) error {
+				fmt.Fprint(opts.Stderr, "container not found\n")
+				return errors.New("unable to upgrade connection")
+			},
+			errMsg: "unable to upgrade connection: container not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdin io.Reader
+			if tt.stdin != "" {
+				stdin = strings.NewReader(tt.stdin)
+			}
+			out, err := OutputStdin(context.Background(), tt.exec, &corev1.Pod{}, stdin, "postqueue", "-p")
+			if out != tt.out {
+				t.Errorf("expected output %q, got %q", tt.out, out)
+			}
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.errMsg {
+				t.Fatalf("expected error %q, got %v", tt.errMsg, err)
+			}
+			var exitErr *ExitError
+			if got := errors.As(err, &exitErr); got != (tt.code != 0) {
+				t.Fatalf("expected ExitError %v, got %v", tt.code != 0, got)
+			}
+			if exitErr != nil && exitErr.Code != tt.code {
+				t.Fatalf("expected exit code %d, got %d", tt.code, exitErr.Code)
+			}
+		})
+	}
+}
+
+func TestShell(t *testing.T) {
+	args := []string{"user@example.com", "it's a \"quoted\" $HOME; rm -rf /", ""}
+	argv := Shell(`printf '%s\n' "$@"`, args...)
+	want := append([]string{"sh", "-c", `printf '%s\n' "$@"`, "sh"}, args...)
+	if !reflect.DeepEqual(argv, want) {
+		t.Fatalf("expected argv %q, got %q", want, argv)
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	// the arguments reach the script verbatim
+	out, err := exec.Command(argv[0], argv[1:]...).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n"); !reflect.DeepEqual(got, args) {
+		t.Fatalf("expected arguments %q, got %q", args, got)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podexec
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RunningPods returns the running pods matching the labels, excluding the terminating ones
+func RunningPods(ctx context.Context, c client.Reader, namespace string, labels map[string]string) ([]corev1.Pod, error) {
+	var list corev1.PodList
+	if err := c.List(ctx, &list, client.InNamespace(namespace), client.MatchingLabels(labels)); err != nil {
+		return nil, err
+	}
+	var pods []corev1.Pod
+	for _, v := range list.Items {
+		if v.Status.Phase == corev1.PodRunning && v.DeletionTimestamp == nil {
+			pods = append(pods, v)
+		}
+	}
+	return pods, nil
+}
+
+// ReadyPod returns a ready pod matching the labels, or nil if none is ready
+func ReadyPod(ctx context.Context, c client.Reader, namespace string, labels map[string]string) (*corev1.Pod, error) {
+	pods, err := RunningPods(ctx, c, namespace, labels)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods {
+		if IsReady(&pods[i]) {
+			return &pods[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// IsReady returns true if the pod Ready condition is true
+func IsReady(pod *corev1.Pod) bool {
+	for _, v := range pod.Status.Conditions {
+		if v.Type == corev1.PodReady {
+			return v.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
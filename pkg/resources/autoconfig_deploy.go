@@ -26,6 +26,9 @@ func AutoConfigDeploy(s *mailv1alpha1.MailServer) *appsv1.Deployment {
 	if s.Spec.AutoConfig.Deployment.Image == "" {
 		s.Spec.AutoConfig.Deployment.Image = "docker.io/linkacloud/autoconfig:latest"
 	}
+	if s.Spec.AutoConfig.Deployment.ProxyImage == "" {
+		s.Spec.AutoConfig.Deployment.ProxyImage = "docker.io/nginxinc/nginx-unprivileged:1.23-alpine"
+	}
 	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        Normalize("autoconfig", s.Spec.Domain),
@@ -45,47 +48,53 @@ func AutoConfigDeploy(s *mailv1alpha1.MailServer) *appsv1.Deployment {
 					Annotations: s.Spec.AutoConfig.Deployment.Annotations,
 				},
 				Spec: corev1.PodSpec{
-					ServiceAccountName:        s.Spec.AutoConfig.Deployment.ServiceAccountName,
-					Affinity:                  s.Spec.AutoConfig.Deployment.Affinity,
-					SecurityContext:           s.Spec.AutoConfig.Deployment.SecurityContext,
-					TopologySpreadConstraints: s.Spec.AutoConfig.Deployment.TopologySpreadConstraints,
-					Tolerations:               s.Spec.AutoConfig.Deployment.Tolerations,
-					NodeSelector:              s.Spec.AutoConfig.Deployment.NodeSelector,
-					RestartPolicy:             corev1.RestartPolicyAlways,
+					ServiceAccountName:            s.Spec.AutoConfig.Deployment.ServiceAccountName,
+					Affinity:                      s.Spec.AutoConfig.Deployment.Affinity,
+					SecurityContext:               s.Spec.AutoConfig.Deployment.SecurityContext,
+					TopologySpreadConstraints:     s.Spec.AutoConfig.Deployment.TopologySpreadConstraints,
+					Tolerations:                   s.Spec.AutoConfig.Deployment.Tolerations,
+					NodeSelector:                  s.Spec.AutoConfig.Deployment.NodeSelector,
+					RestartPolicy:                 corev1.RestartPolicyAlways,
+					TerminationGracePeriodSeconds: s.Spec.AutoConfig.Deployment.TerminationGracePeriodSeconds,
 					Containers: []corev1.Container{
 						{
 							Name:      "autoconfig",
 							Image:     s.Spec.AutoConfig.Deployment.Image,
 							Resources: s.Spec.AutoConfig.Deployment.Resources,
-							Env: append(
-								[]corev1.EnvVar{
-									{
-										Name:  "DOMAIN",
-										Value: s.Spec.Domain,
-									},
-									{
-										Name:  "IMAP_SERVER",
-										Value: "mail." + s.Spec.Domain,
-									},
-									{
-										Name:  "SMTP_SERVER",
-										Value: "mail." + s.Spec.Domain,
-									},
-								},
-								s.Spec.AutoConfig.Deployment.Env...,
-							),
+							Env:       append(autoConfigEnv(s), s.Spec.AutoConfig.Deployment.Env...),
 							Ports: []corev1.ContainerPort{
 								{
-									ContainerPort: 1323,
+									ContainerPort: autoConfigPort,
 									Protocol:      corev1.ProtocolTCP,
 								},
 							},
 							VolumeMounts: s.Spec.AutoConfig.Deployment.VolumeMounts,
 						},
+						autoConfigProxyContainer(s),
 					},
-					Volumes: s.Spec.AutoConfig.Deployment.Volumes,
+					Volumes: concat(s.Spec.AutoConfig.Deployment.Volumes, autoConfigProxyVolumes(s)),
 				},
 			},
 		},
 	}
 }
+
+// autoConfigEnv returns the autoconfig environment.
+// The linkacloud/autoconfig image only advertises the IMAP and SMTP servers,
+// the Thunderbird configuration advertising OAuth2 is served by the proxy, see autoConfigProxyConfig.
+func autoConfigEnv(s *mailv1alpha1.MailServer) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{
+			Name:  "DOMAIN",
+			Value: s.Spec.Domain,
+		},
+		{
+			Name:  "IMAP_SERVER",
+			Value: "mail." + s.Spec.Domain,
+		},
+		{
+			Name:  "SMTP_SERVER",
+			Value: "mail." + s.Spec.Domain,
+		},
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+const (
+	// autoConfigPort is the autoconfig container port, only reached through the proxy
+	autoConfigPort = 1323
+	// autoConfigProxyPort is the proxy port targeted by the autoconfig service
+	autoConfigProxyPort = 8080
+
+	autoConfigProxyConfigFile = "default.conf"
+	// autoConfigThunderbirdFile is the Thunderbird configuration served instead of the autoconfig image one
+	autoConfigThunderbirdFile = "config-v1.1.xml"
+	autoConfigProxyVolume     = "autoconfig-proxy"
+)
+
+// AutoConfigProxySecret returns the secret containing the autoconfig proxy configuration
+func AutoConfigProxySecret(s *mailv1alpha1.MailServer) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Normalize("autoconfig-proxy", s.Spec.Domain),
+			Namespace: s.Namespace,
+			Labels:    Labels(s, "autoconfig-proxy"),
+		},
+		Data: autoConfigProxyFiles(s),
+	}
+}
+
+// autoConfigProxyFiles renders the proxy configuration,
+// along with the Thunderbird configuration advertising OAuth2 when it is enabled
+func autoConfigProxyFiles(s *mailv1alpha1.MailServer) map[string][]byte {
+	files := map[string][]byte{
+		autoConfigProxyConfigFile: autoConfigProxyConfig(s),
+	}
+	if s.Spec.Features.OIDC.Enabled {
+		files[autoConfigThunderbirdFile] = autoConfigThunderbirdConfig(s)
+	}
+	return files
+}
+
+// autoConfigProxyConfig renders the nginx server forwarding the requests to the autoconfig container,
+// or answering them with a maintenance response while the mail server is in maintenance.
+// The Thunderbird configuration is served by the proxy when OAuth2 is enabled, as the autoconfig image does not advertise it.
+func autoConfigProxyConfig(s *mailv1alpha1.MailServer) []byte {
+	var b strings.Builder
+	b.WriteString("# generated by kube-mailserver, do not edit\n")
+	b.WriteString("server {\n")
+	fmt.Fprintf(&b, "  listen %d;\n", autoConfigProxyPort)
+	if s.Spec.Maintenance {
+		b.WriteString("  location / {\n")
+		b.WriteString("    default_type text/plain;\n")
+		b.WriteString("    add_header Retry-After 3600 always;\n")
+		fmt.Fprintf(&b, "    return 503 'the %s mail server is under maintenance\\n';\n", s.Spec.Domain)
+		b.WriteString("  }\n")
+	} else {
+		if s.Spec.Features.OIDC.Enabled {
+			for _, v := range []string{"/mail/", "/.well-known/autoconfig/mail/"} {
+				fmt.Fprintf(&b, "  location = %s%s {\n", v, autoConfigThunderbirdFile)
+				b.WriteString("    default_type application/xml;\n")
+				fmt.Fprintf(&b, "    alias /etc/nginx/conf.d/%s;\n", autoConfigThunderbirdFile)
+				b.WriteString("  }\n")
+			}
+		}
+		b.WriteString("  location / {\n")
+		fmt.Fprintf(&b, "    proxy_pass http://127.0.0.1:%d;\n", autoConfigPort)
+		b.WriteString("    proxy_set_header Host $host;\n")
+		b.WriteString("    proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;\n")
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+// autoConfigThunderbirdConfig renders the Thunderbird autoconfig configuration of the enabled protocols,
+// offering OAuth2 before the password authentication
+func autoConfigThunderbirdConfig(s *mailv1alpha1.MailServer) []byte {
+	o := s.Spec.Features.OIDC
+	host := "mail." + s.Spec.Domain
+	server := func(b *strings.Builder, tag, typ string, port int, socket string) {
+		fmt.Fprintf(b, "    <%s type=\"%s\">\n", tag, typ)
+		fmt.Fprintf(b, "      <hostname>%s</hostname>\n", xmlEscape(host))
+		fmt.Fprintf(b, "      <port>%d</port>\n", port)
+		fmt.Fprintf(b, "      <socketType>%s</socketType>\n", socket)
+		b.WriteString("      <authentication>OAuth2</authentication>\n")
+		b.WriteString("      <authentication>password-cleartext</authentication>\n")
+		b.WriteString("      <username>%EMAILADDRESS%</username>\n")
+		fmt.Fprintf(b, "    </%s>\n", tag)
+	}
+	var b strings.Builder
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	b.WriteString("<!-- generated by kube-mailserver, do not edit -->\n")
+	b.WriteString("<clientConfig version=\"1.1\">\n")
+	fmt.Fprintf(&b, "  <emailProvider id=\"%s\">\n", xmlEscape(s.Spec.Domain))
+	fmt.Fprintf(&b, "    <domain>%s</domain>\n", xmlEscape(s.Spec.Domain))
+	fmt.Fprintf(&b, "    <displayName>%s</displayName>\n", xmlEscape(s.Spec.Domain))
+	server(&b, "incomingServer", "imap", 993, "SSL")
+	if V(s.Spec.Features.POP3) {
+		server(&b, "incomingServer", "pop3", 995, "SSL")
+	}
+	server(&b, "outgoingServer", "smtp", 587, "STARTTLS")
+	b.WriteString("  </emailProvider>\n")
+	b.WriteString("  <oAuth2>\n")
+	issuer := o.Issuer
+	if u, err := url.Parse(o.Issuer); err == nil && u.Host != "" {
+		issuer = u.Host
+	}
+	fmt.Fprintf(&b, "    <issuer>%s</issuer>\n", xmlEscape(issuer))
+	fmt.Fprintf(&b, "    <scope>%s</scope>\n", xmlEscape(strings.Join(oidcScopes(o), " ")))
+	fmt.Fprintf(&b, "    <authURL>%s</authURL>\n", xmlEscape(oidcEndpoint(o, o.AuthorizationURL, "auth")))
+	fmt.Fprintf(&b, "    <tokenURL>%s</tokenURL>\n", xmlEscape(oidcEndpoint(o, o.TokenURL, "token")))
+	b.WriteString("  </oAuth2>\n")
+	b.WriteString("</clientConfig>\n")
+	return []byte(b.String())
+}
+
+func xmlEscape(v string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(v))
+	return b.String()
+}
+
+func autoConfigProxyContainer(s *mailv1alpha1.MailServer) corev1.Container {
+	return corev1.Container{
+		Name:  "proxy",
+		Image: s.Spec.AutoConfig.Deployment.ProxyImage,
+		Ports: []corev1.ContainerPort{
+			{
+				Name:          "http",
+				ContainerPort: autoConfigProxyPort,
+				Protocol:      corev1.ProtocolTCP,
+			},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      autoConfigProxyVolume,
+				MountPath: "/etc/nginx/conf.d",
+				ReadOnly:  true,
+			},
+		},
+	}
+}
+
+func autoConfigProxyVolumes(s *mailv1alpha1.MailServer) []corev1.Volume {
+	return []corev1.Volume{
+		{
+			Name: autoConfigProxyVolume,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: Normalize("autoconfig-proxy", s.Spec.Domain),
+				},
+			},
+		},
+	}
+}
@@ -35,7 +35,7 @@ func AutoConfigService(s *mailv1alpha1.MailServer) *corev1.Service {
 				{
 					Name:       "http",
 					Port:       80,
-					TargetPort: intstr.FromInt(1323),
+					TargetPort: intstr.FromInt(autoConfigProxyPort),
 				},
 			},
 		},
@@ -0,0 +1,149 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+const (
+	// ClamavPort is the clamd TCP port
+	ClamavPort = 3310
+
+	freshclamFile = "freshclam.conf"
+)
+
+// ClamavDeploy returns the clamd deployment.
+// Without a shared cache, each replica also runs freshclam to download its own signatures.
+func ClamavDeploy(s *mailv1alpha1.MailServer) *appsv1.Deployment {
+	c := s.Spec.Features.ClamavServer
+	var env []corev1.EnvVar
+	if c.Cache != nil {
+		env = append(env, corev1.EnvVar{Name: "CLAMAV_NO_FRESHCLAMD", Value: "true"})
+	}
+	d := clamavDeploy(s, "clamav", P(V(c.Replicas, 1)), env)
+	probe := func(period, failures int32) *corev1.Probe {
+		return &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				TCPSocket: &corev1.TCPSocketAction{
+					Port: intstr.FromInt(ClamavPort),
+				},
+			},
+			PeriodSeconds:    period,
+			FailureThreshold: failures,
+		}
+	}
+	container := &d.Spec.Template.Spec.Containers[0]
+	container.Ports = []corev1.ContainerPort{
+		{
+			Name:          "clamd",
+			ContainerPort: ClamavPort,
+			Protocol:      corev1.ProtocolTCP,
+		},
+	}
+	// loading the signatures takes a few minutes, and the first download even more
+	container.StartupProbe = probe(10, 60)
+	container.ReadinessProbe = probe(10, 3)
+	container.LivenessProbe = probe(30, 3)
+	return d
+}
+
+// FreshclamDeploy returns the deployment downloading the signatures in the shared cache, only used with a cache volume
+func FreshclamDeploy(s *mailv1alpha1.MailServer) *appsv1.Deployment {
+	d := clamavDeploy(s, "freshclam", P(int32(1)), []corev1.EnvVar{{Name: "CLAMAV_NO_CLAMD", Value: "true"}})
+	// a single freshclam must write the signatures
+	d.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+	return d
+}
+
+func clamavDeploy(s *mailv1alpha1.MailServer, name string, replicas *int32, env []corev1.EnvVar) *appsv1.Deployment {
+	c := s.Spec.Features.ClamavServer
+	d := c.Deployment
+	if d.Image == "" {
+		d.Image = "docker.io/clamav/clamav:stable"
+	}
+	signatures := corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+	if c.Cache != nil {
+		signatures = corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: Normalize("clamav", s.Spec.Domain, "cache"),
+			},
+		}
+	}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Normalize(name, s.Spec.Domain),
+			Namespace: s.Namespace,
+			Labels:    Labels(s, name),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: Labels(s, name),
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: Labels(s, name),
+				},
+				Spec: corev1.PodSpec{
+					Affinity:                  d.Affinity,
+					TopologySpreadConstraints: d.TopologySpreadConstraints,
+					Tolerations:               d.Tolerations,
+					NodeSelector:              d.NodeSelector,
+					RestartPolicy:             corev1.RestartPolicyAlways,
+					Containers: []corev1.Container{
+						{
+							Name:      name,
+							Image:     d.Image,
+							Resources: d.Resources,
+							Env:       env,
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "signatures",
+									MountPath: "/var/lib/clamav",
+								},
+								{
+									Name:      "config",
+									MountPath: "/etc/clamav/" + freshclamFile,
+									SubPath:   freshclamFile,
+									ReadOnly:  true,
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name:         "signatures",
+							VolumeSource: signatures,
+						},
+						{
+							Name: "config",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{
+									SecretName: Normalize("clamav", s.Spec.Domain),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+// ClamavConfigSecret returns the secret containing the freshclam configuration
+func ClamavConfigSecret(s *mailv1alpha1.MailServer) *corev1.Secret {
+	c := s.Spec.Features.ClamavServer
+	mirror := c.Mirror
+	if mirror == "" {
+		mirror = "database.clamav.net"
+	}
+	lines := []string{
+		"# generated by kube-mailserver, do not edit",
+		"DatabaseDirectory /var/lib/clamav",
+		"DatabaseOwner clamav",
+		"PidFile /tmp/freshclam.pid",
+		"LogTime yes",
+		"DatabaseMirror " + mirror,
+	}
+	if c.Cache == nil {
+		lines = append(lines, "NotifyClamd /etc/clamav/clamd.conf")
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Normalize("clamav", s.Spec.Domain),
+			Namespace: s.Namespace,
+			Labels:    Labels(s, "clamav-config"),
+		},
+		Data: map[string][]byte{
+			freshclamFile: []byte(strings.Join(lines, "\n") + "\n"),
+		},
+	}
+}
+
+// amavisClamavConfig renders the amavis.cf file enabling the virus checks with the clamd service,
+// the mails are streamed as clamd does not share the amavis filesystem
+func amavisClamavConfig(s *mailv1alpha1.MailServer) []byte {
+	var b strings.Builder
+	b.WriteString("# generated by kube-mailserver, do not edit\n")
+	b.WriteString("use strict;\n\n")
+	b.WriteString("@bypass_virus_checks_maps = (\n")
+	b.WriteString("  \\%bypass_virus_checks, \\@bypass_virus_checks_acl, \\$bypass_virus_checks_re);\n\n")
+	b.WriteString("@av_scanners = (\n")
+	b.WriteString("  ['ClamAV-clamd-stream',\n")
+	fmt.Fprintf(&b, "    \\&ask_daemon, [\"*\", 'clamd:%s'],\n", ClamavAddress(s))
+	b.WriteString("    qr/\\bOK$/m, qr/\\bFOUND$/m,\n")
+	b.WriteString("    qr/^.*?: (?!Infected Archive)(.*) FOUND$/m ],\n")
+	b.WriteString(");\n\n")
+	b.WriteString("@av_scanners_backup = ();\n\n")
+	b.WriteString("1;\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,50 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+func ClamavService(s *mailv1alpha1.MailServer) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Normalize("clamav", s.Spec.Domain),
+			Namespace: s.Namespace,
+			Labels:    Labels(s, "clamav-service"),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: Labels(s, "clamav"),
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "clamd",
+					Port:       ClamavPort,
+					TargetPort: intstr.FromInt(ClamavPort),
+				},
+			},
+		},
+	}
+}
+
+// ClamavAddress returns the clamd service address
+func ClamavAddress(s *mailv1alpha1.MailServer) string {
+	return fmt.Sprintf("%s:%d", Normalize("clamav", s.Spec.Domain), ClamavPort)
+}
@@ -64,3 +64,12 @@ func V[T any, P PT[T]](p P, defaults ...T) (v T) {
 	}
 	return v
 }
+
+// concat returns a new slice containing the elements of all the slices
+func concat[T any](ss ...[]T) []T {
+	var out []T
+	for _, v := range ss {
+		out = append(out, v...)
+	}
+	return out
+}
@@ -38,7 +38,8 @@ type AccountProvisioner string
 const (
 	// AccountProvisionerLDAP is the LDAP account provisioner
 	AccountProvisionerLDAP AccountProvisioner = "ldap"
-	// AccountProvisionerOIDC is the OIDC account provisioner (not implemented yet)
+	// AccountProvisionerOIDC is the OIDC account provisioner (not implemented by docker-mailserver),
+	// OAuth2 authentication is configured with the oidc feature on top of the file or LDAP provisioner
 	AccountProvisionerOIDC AccountProvisioner = "oidc"
 	// AccountProvisionerFile is the file account provisioner (not implemented yet)
 	AccountProvisionerFile AccountProvisioner = "file"
@@ -74,7 +75,7 @@ type MailServerConfig struct {
 	// AccountProvisioner
 	// **empty** => use FILE
 	// LDAP => use LDAP authentication
-	// OIDC => use OIDC authentication (not yet implemented)
+	// OIDC => use OIDC authentication (not implemented, see the oidc feature)
 	// FILE => use local files (this is used as the default)
 	AccountProvisioner AccountProvisioner `json:"accountProvisioner,omitempty" env:"ACCOUNT_PROVISIONER"`
 	// PostmasterAddress
@@ -169,6 +170,17 @@ type MailServerConfig struct {
 	// 3/4/5    => log debug information (very verbose)
 	AmavisLoglevel string `json:"amavisLoglevel,omitempty" env:"AMAVIS_LOGLEVEL"`
 
+	// EnableOpenDKIM enables the OpenDKIM service, enabled by default in docker-mailserver
+	EnableOpenDKIM bool `json:"enableOpendkim,omitempty" env:"ENABLE_OPENDKIM"`
+	// EnableOpenDMARC enables the OpenDMARC service, enabled by default in docker-mailserver
+	EnableOpenDMARC bool `json:"enableOpendmarc,omitempty" env:"ENABLE_OPENDMARC"`
+	// EnablePolicydSPF enables the policyd-spf service, enabled by default in docker-mailserver
+	EnablePolicydSPF bool `json:"enablePolicydSpf,omitempty" env:"ENABLE_POLICYD_SPF"`
+	// EnableRspamd enables the Rspamd service
+	EnableRspamd bool `json:"enableRspamd,omitempty" env:"ENABLE_RSPAMD"`
+	// EnableRspamdRedis enables the Redis instance embedded in the mail server container, used by Rspamd
+	EnableRspamdRedis bool `json:"enableRspamdRedis,omitempty" env:"ENABLE_RSPAMD_REDIS"`
+
 	// EnableDNSBL enables DNS BlackList service
 	// This enables the [zen.spamhaus.org](https://www.spamhaus.org/zen/) DNS block list in postfix
 	// and various [lists](https://github.com/docker-mailserver/docker-mailserver/blob/f7465a50888eef909dbfc01aff4202b9c7d8bc00/target/postfix/main.cf//L58-L66) in postscreen.
@@ -557,6 +569,17 @@ func (c MailServerConfig) ToMap() map[string][]byte {
 				} else {
 					m[k] = []byte("no")
 				}
+			// these are enabled by default by docker-mailserver if left empty
+			case "ENABLE_AMAVIS",
+				"ENABLE_OPENDKIM",
+				"ENABLE_OPENDMARC",
+				"ENABLE_POLICYD_SPF",
+				"ENABLE_RSPAMD_REDIS":
+				if v {
+					m[k] = []byte("1")
+				} else {
+					m[k] = []byte("0")
+				}
 			default:
 				if v {
 					m[k] = []byte("1")
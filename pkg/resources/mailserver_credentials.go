@@ -23,19 +23,59 @@ import (
 	mv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
 )
 
+// PostmasterAddress returns the postmaster mailbox address
+func PostmasterAddress(s *mv1alpha1.MailServer) string {
+	if s.Spec.Postmaster.Address != "" {
+		return s.Spec.Postmaster.Address
+	}
+	// the deprecated config field is still honored, the mailbox is created for it too
+	if s.Spec.Config.PostmasterAddress != "" {
+		return s.Spec.Config.PostmasterAddress
+	}
+	return fmt.Sprintf("postmaster@%s", s.Spec.Domain)
+}
+
+// PostmasterSecretName returns the name of the secret holding the postmaster password,
+// either the existing one or the generated one
+func PostmasterSecretName(s *mv1alpha1.MailServer) string {
+	if s.Spec.Postmaster.ExistingSecret != "" {
+		return s.Spec.Postmaster.ExistingSecret
+	}
+	return Normalize("postmaster", s.Spec.Domain)
+}
+
+// MailServerCredentials returns the generated postmaster secret, it returns nil if an existing secret is used
 func MailServerCredentials(s *mv1alpha1.MailServer, password string) *corev1.Secret {
+	if s.Spec.Postmaster.ExistingSecret != "" {
+		return nil
+	}
 	if password == "" {
 		password = RandomPassword()
 	}
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      Normalize("postmaster", s.Spec.Domain),
+			Name:      PostmasterSecretName(s),
 			Namespace: s.Namespace,
 			Labels:    Labels(s, "credentials"),
 		},
 		Data: map[string][]byte{
-			"email":    []byte(fmt.Sprintf("postmaster@%s", s.Spec.Domain)),
+			"email":    []byte(PostmasterAddress(s)),
 			"password": []byte(password),
 		},
 	}
 }
+
+// MailServerSRSSecret returns the Sender Rewriting Scheme secret.
+// It holds a comma separated list of secrets: the first one is used for signing, the others only for validation.
+func MailServerSRSSecret(s *mv1alpha1.MailServer) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Normalize("srs", s.Spec.Domain),
+			Namespace: s.Namespace,
+			Labels:    Labels(s, "srs"),
+		},
+		Data: map[string][]byte{
+			"secrets": []byte(RandomPassword()),
+		},
+	}
+}
@@ -15,8 +15,6 @@
 package resources
 
 import (
-	"fmt"
-
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -24,8 +22,44 @@ import (
 	mv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
 )
 
-func MailServerDeploy(s *mv1alpha1.MailServer) *appsv1.Deployment {
+// mailServerPublicServices are the public Postfix listeners disabled while draining,
+// the local services, e.g. the Amavis re-injection smtpd on 127.0.0.1:10025, must keep running to flush the queue
+const mailServerPublicServices = "smtp/inet submission/inet submissions/inet smtps/inet"
+
+// MailServerDrainCommand stops accepting new connections, flushes the Postfix queue
+// and prints the number of messages still queued
+const MailServerDrainCommand = `(postconf -h master_service_disable | grep -qF smtp/inet || (postconf -e 'master_service_disable = ` + mailServerPublicServices + `' && postfix reload >/dev/null 2>&1)) && postqueue -f && postqueue -j | wc -l`
+
+// MailServerUndrainCommand accepts new connections again after a drain
+const MailServerUndrainCommand = `postconf -e 'master_service_disable =' && postfix reload >/dev/null 2>&1`
+
+// postmasterSetupScript creates the $POSTMASTER_EMAIL mailbox or updates its password if it already exists
+const postmasterSetupScript = `( (listmailuser | grep -qwF "$POSTMASTER_EMAIL" && echo "Updating Postmaster password $POSTMASTER_EMAIL" && updatemailuser "$POSTMASTER_EMAIL" "$POSTMASTER_PASSWORD") || (echo "Creating Postmaster email $POSTMASTER_EMAIL" && addmailuser "$POSTMASTER_EMAIL" "$POSTMASTER_PASSWORD") )`
+
+// MailServerPostmasterCommand returns the argv applying the postmaster password to the mailbox of a running mail server.
+// The password is read from the standard input so that it never appears in the command line.
+func MailServerPostmasterCommand(address string) []string {
+	return []string{"env", "POSTMASTER_EMAIL=" + address, "bash", "-c", `IFS= read -r POSTMASTER_PASSWORD && ` + postmasterSetupScript}
+}
+
+// mailServerPreStopCommand drains the Postfix queue before the pod is terminated,
+// the kubelet kills the container anyway once the termination grace period expires
+var mailServerPreStopCommand = MailServerDrainCommand + ` >/dev/null; while [ "$(postqueue -j | wc -l)" -gt 0 ]; do sleep 2; done`
+
+// MailServerDeploy returns the mail server deployment, mounting the operator rendered files and the validated overrides if any
+func MailServerDeploy(s *mv1alpha1.MailServer, files *corev1.Secret, overrides map[string][]byte) *appsv1.Deployment {
 	labels := Labels(s, "server")
+	replicas := s.Spec.Replicas
+	if s.Spec.Maintenance {
+		replicas = P(int32(0))
+	}
+	strategy := s.Spec.Strategy
+	if strategy.Type == "" {
+		strategy.Type = appsv1.RecreateDeploymentStrategyType
+	}
+	overridesVolumes, overridesMounts := mailServerOverridesVolumes(s, overrides)
+	ldapVolumes, ldapMounts := mailServerLDAPTLSVolumes(s)
+	exporterVolumes, exporterMounts := mailServerExporterVolumes(s)
 	deploy := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        Normalize("mail", s.Spec.Domain),
@@ -34,50 +68,44 @@ func MailServerDeploy(s *mv1alpha1.MailServer) *appsv1.Deployment {
 			Annotations: s.Spec.Annotations,
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: s.Spec.Replicas,
+			Replicas: replicas,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: Labels(s, "server"),
 			},
-			Strategy: s.Spec.Strategy,
+			Strategy: strategy,
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels:      labels,
 					Annotations: s.Spec.Annotations,
 				},
 				Spec: corev1.PodSpec{
-					Affinity:                  s.Spec.Affinity,
-					SecurityContext:           s.Spec.SecurityContext,
-					TopologySpreadConstraints: s.Spec.TopologySpreadConstraints,
-					Tolerations:               s.Spec.Tolerations,
-					NodeSelector:              s.Spec.NodeSelector,
-					Hostname:                  "mail",
-					RestartPolicy:             corev1.RestartPolicyAlways,
+					Affinity:                      s.Spec.Affinity,
+					SecurityContext:               s.Spec.SecurityContext,
+					TopologySpreadConstraints:     s.Spec.TopologySpreadConstraints,
+					Tolerations:                   s.Spec.Tolerations,
+					NodeSelector:                  s.Spec.NodeSelector,
+					Hostname:                      "mail",
+					RestartPolicy:                 corev1.RestartPolicyAlways,
+					TerminationGracePeriodSeconds: P(V(s.Spec.TerminationGracePeriodSeconds, 120)),
 					InitContainers: []corev1.Container{
 						{
 							Name:            "setup",
 							Image:           s.Spec.Image,
 							ImagePullPolicy: corev1.PullIfNotPresent,
 							Command:         []string{"/bin/bash"},
-							Args:            []string{"-c", fmt.Sprintf(`( listmailuser|grep -s $POSTMASTER_EMAIL || (echo "Creating Postmaster email $POSTMASTER_EMAIL" && addmailuser $POSTMASTER_EMAIL $POSTMASTER_PASSWORD)) && ( test -f /tmp/docker-mailserver/opendkim/keys/${MAIL_DOMAIN}/mail.private || (echo "Generating DKIM Private Key" && open-dkim) )`)},
+							Args:            []string{"-c", postmasterSetupScript + ` && ( test -f /tmp/docker-mailserver/opendkim/keys/${MAIL_DOMAIN}/mail.private || (echo "Generating DKIM Private Key" && open-dkim) )`},
 							Env: append(
 								[]corev1.EnvVar{
 									{
-										Name: "POSTMASTER_EMAIL",
-										ValueFrom: &corev1.EnvVarSource{
-											SecretKeyRef: &corev1.SecretKeySelector{
-												LocalObjectReference: corev1.LocalObjectReference{
-													Name: Normalize("postmaster", s.Spec.Domain),
-												},
-												Key: "email",
-											},
-										},
+										Name:  "POSTMASTER_EMAIL",
+										Value: PostmasterAddress(s),
 									},
 									{
 										Name: "POSTMASTER_PASSWORD",
 										ValueFrom: &corev1.EnvVarSource{
 											SecretKeyRef: &corev1.SecretKeySelector{
 												LocalObjectReference: corev1.LocalObjectReference{
-													Name: Normalize("postmaster", s.Spec.Domain),
+													Name: PostmasterSecretName(s),
 												},
 												Key: "password",
 											},
@@ -104,7 +132,7 @@ func MailServerDeploy(s *mv1alpha1.MailServer) *appsv1.Deployment {
 							VolumeMounts:    append(mailServerDeployVolumeMounts, s.Spec.VolumeMounts...),
 						},
 					},
-					Containers: []corev1.Container{
+					Containers: append([]corev1.Container{
 						{
 							Name:            "mailserver",
 							Image:           s.Spec.Image,
@@ -119,7 +147,7 @@ func MailServerDeploy(s *mv1alpha1.MailServer) *appsv1.Deployment {
 									},
 								},
 							},
-							Env: []corev1.EnvVar{
+							Env: append([]corev1.EnvVar{
 								{
 									Name:  "SSL_TYPE",
 									Value: "manual",
@@ -132,45 +160,22 @@ func MailServerDeploy(s *mv1alpha1.MailServer) *appsv1.Deployment {
 									Name:  "SSL_KEY_PATH",
 									Value: "/etc/mailserver/ssl/tls.key",
 								},
-							},
+							}, mailServerSRSEnv(s)...),
 							SecurityContext: &mailServerDeploySecurityContext,
-							VolumeMounts:    append(mailServerDeployVolumeMounts, s.Spec.VolumeMounts...),
-							Ports: []corev1.ContainerPort{
-								{
-									Name:          "smtp",
-									ContainerPort: 25,
-								},
-								{
-									Name:          "imap",
-									ContainerPort: 143,
-								},
-								{
-									Name:          "esmtp-implicit",
-									ContainerPort: 465,
-								},
-								{
-									Name:          "esmtp-explicit",
-									ContainerPort: 587,
-								},
-								{
-									Name:          "imap-implicit",
-									ContainerPort: 993,
-								},
-								{
-									Name:          "pop3",
-									ContainerPort: 110,
-								},
-								{
-									Name:          "pop3s",
-									ContainerPort: 995,
-								},
-								{
-									Name:          "sieve",
-									ContainerPort: 4190,
+							VolumeMounts:    concat(mailServerDeployVolumeMounts, mailServerFilesVolumeMounts(files), overridesMounts, ldapMounts, exporterMounts, s.Spec.VolumeMounts),
+							Ports:           mailServerPorts(s),
+							StartupProbe:    mailServerStartupProbe(s),
+							ReadinessProbe:  mailServerReadinessProbe(s),
+							LivenessProbe:   mailServerLivenessProbe(s),
+							Lifecycle: &corev1.Lifecycle{
+								PreStop: &corev1.LifecycleHandler{
+									Exec: &corev1.ExecAction{
+										Command: []string{"/bin/bash", "-c", mailServerPreStopCommand},
+									},
 								},
 							},
 						},
-					},
+					}, mailServerExporterContainers(s)...),
 					Volumes: append(
 						[]corev1.Volume{
 							{
@@ -190,7 +195,7 @@ func MailServerDeploy(s *mv1alpha1.MailServer) *appsv1.Deployment {
 								},
 							},
 						},
-						s.Spec.Volumes...,
+						concat(mailServerFilesVolumes(files), overridesVolumes, ldapVolumes, exporterVolumes, s.Spec.Volumes)...,
 					),
 				},
 			},
@@ -205,6 +210,40 @@ func MailServerDeploy(s *mv1alpha1.MailServer) *appsv1.Deployment {
 	return deploy
 }
 
+// ConfigChecksumAnnotation is the pod template annotation holding the checksum of the mail server configuration,
+// the pods are rolled when it changes
+const ConfigChecksumAnnotation = "mail.linka.cloud/config-checksum"
+
+// SetPodAnnotation sets a deployment pod template annotation without mutating the spec annotations
+func SetPodAnnotation(d *appsv1.Deployment, k, v string) {
+	annotations := make(map[string]string, len(d.Spec.Template.Annotations)+1)
+	for k, v := range d.Spec.Template.Annotations {
+		annotations[k] = v
+	}
+	annotations[k] = v
+	d.Spec.Template.Annotations = annotations
+}
+
+// mailServerSRSEnv passes the SRS secrets from the srs secret, so that they are never copied in the config secret
+func mailServerSRSEnv(s *mv1alpha1.MailServer) []corev1.EnvVar {
+	if !s.Spec.Features.SRS.Enabled {
+		return nil
+	}
+	return []corev1.EnvVar{
+		{
+			Name: "SRS_SECRET",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: Normalize("srs", s.Spec.Domain),
+					},
+					Key: "secrets",
+				},
+			},
+		},
+	}
+}
+
 var (
 	mailServerDeploySecurityContext = corev1.SecurityContext{
 		AllowPrivilegeEscalation: P(false),
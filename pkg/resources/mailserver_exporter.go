@@ -0,0 +1,204 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+const (
+	PostfixExporterImage = "docker.io/boky/postfix-exporter:latest"
+	DovecotExporterImage = "docker.io/kumina/dovecot-exporter:latest"
+
+	PostfixExporterPort = 9154
+	DovecotExporterPort = 9166
+
+	// mailServerLogDir is the docker-mailserver log directory, shared with the Postfix exporter which parses the mail log
+	mailServerLogDir = "/var/log/mail"
+	// dovecotStatsDir holds the Dovecot old-stats socket queried by the Dovecot exporter
+	dovecotStatsDir = "/var/run/dovecot-stats"
+
+	dovecotStatsFile = "dovecot-stats.conf"
+)
+
+// mailServerExporterContainers returns the Postfix and Dovecot exporters sidecars
+func mailServerExporterContainers(s *mailv1alpha1.MailServer) []corev1.Container {
+	e := s.Spec.Monitoring.Exporter
+	if !e.Enabled {
+		return nil
+	}
+	return []corev1.Container{
+		{
+			Name:            "postfix-exporter",
+			Image:           exporterImage(e.Postfix, PostfixExporterImage),
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Args: []string{
+				"--postfix.showq_path=/var/spool/postfix/public/showq",
+				"--postfix.logfile_path=" + path.Join(mailServerLogDir, "mail.log"),
+				fmt.Sprintf("--web.listen-address=:%d", PostfixExporterPort),
+			},
+			Ports: []corev1.ContainerPort{
+				{
+					Name:          "postfix-metrics",
+					ContainerPort: PostfixExporterPort,
+				},
+			},
+			Resources: e.Postfix.Resources,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "mail-data",
+					MountPath: "/var/spool/postfix",
+					SubPath:   "volumes/mailstate/spool-postfix",
+					ReadOnly:  true,
+				},
+				{
+					Name:      "mail-logs",
+					MountPath: mailServerLogDir,
+					ReadOnly:  true,
+				},
+			},
+		},
+		{
+			Name:            "dovecot-exporter",
+			Image:           exporterImage(e.Dovecot, DovecotExporterImage),
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Args: []string{
+				"--dovecot.socket-path=" + path.Join(dovecotStatsDir, "old-stats"),
+				"--dovecot.scopes=user",
+				fmt.Sprintf("--web.listen-address=:%d", DovecotExporterPort),
+			},
+			Ports: []corev1.ContainerPort{
+				{
+					Name:          "dovecot-metrics",
+					ContainerPort: DovecotExporterPort,
+				},
+			},
+			Resources: e.Dovecot.Resources,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "dovecot-stats",
+					MountPath: dovecotStatsDir,
+				},
+			},
+		},
+	}
+}
+
+// mailServerExporterVolumes returns the log and Dovecot stats volumes shared between the mail server and the exporters
+func mailServerExporterVolumes(s *mailv1alpha1.MailServer) ([]corev1.Volume, []corev1.VolumeMount) {
+	if !s.Spec.Monitoring.Exporter.Enabled {
+		return nil, nil
+	}
+	return []corev1.Volume{
+		{
+			Name:         "mail-logs",
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		},
+		{
+			Name:         "dovecot-stats",
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		},
+	}, []corev1.VolumeMount{
+		{
+			Name:      "mail-logs",
+			MountPath: mailServerLogDir,
+		},
+		{
+			Name:      "dovecot-stats",
+			MountPath: dovecotStatsDir,
+		},
+	}
+}
+
+func exporterImage(c mailv1alpha1.ExporterContainer, image string) string {
+	if c.Image != "" {
+		return c.Image
+	}
+	return image
+}
+
+// dovecotStatsConfig enables the Dovecot old stats plugins and exposes their socket in the shared stats directory
+func dovecotStatsConfig() []byte {
+	var b strings.Builder
+	b.WriteString("# generated by kube-mailserver, do not edit\n")
+	b.WriteString("mail_plugins = $mail_plugins old_stats\n")
+	b.WriteString("protocol imap {\n")
+	b.WriteString("  mail_plugins = $mail_plugins imap_old_stats\n")
+	b.WriteString("}\n")
+	b.WriteString("plugin {\n")
+	b.WriteString("  old_stats_refresh = 30 secs\n")
+	b.WriteString("  old_stats_track_cmds = yes\n")
+	b.WriteString("}\n")
+	b.WriteString("service old-stats {\n")
+	fmt.Fprintf(&b, "  unix_listener %s {\n", path.Join(dovecotStatsDir, "old-stats"))
+	b.WriteString("    mode = 0666\n")
+	b.WriteString("  }\n")
+	b.WriteString("  fifo_listener old-stats-mail {\n")
+	b.WriteString("    mode = 0660\n")
+	b.WriteString("    user = docker\n")
+	b.WriteString("  }\n")
+	b.WriteString("  fifo_listener old-stats-user {\n")
+	b.WriteString("    mode = 0660\n")
+	b.WriteString("    user = docker\n")
+	b.WriteString("  }\n")
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+// exporterPatches installs the Dovecot stats configuration
+func exporterPatches(s *mailv1alpha1.MailServer) []string {
+	if !s.Spec.Monitoring.Exporter.Enabled {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("install -m 0644 %s /etc/dovecot/conf.d/95-stats.conf", path.Join(mailServerConfigDir, dovecotStatsFile)),
+	}
+}
+
+// MailServerMetricsService returns the ClusterIP service exposing the exporters,
+// so that the metrics are never published by the mail server load balancer
+func MailServerMetricsService(s *mailv1alpha1.MailServer) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Normalize("mail", s.Spec.Domain, "metrics"),
+			Namespace: s.Namespace,
+			Labels:    Labels(s, "metrics"),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: Labels(s, "server"),
+			Type:     corev1.ServiceTypeClusterIP,
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "postfix-metrics",
+					Port:       PostfixExporterPort,
+					TargetPort: intstr.FromString("postfix-metrics"),
+				},
+				{
+					Name:       "dovecot-metrics",
+					Port:       DovecotExporterPort,
+					TargetPort: intstr.FromString("dovecot-metrics"),
+				},
+			},
+		},
+	}
+}
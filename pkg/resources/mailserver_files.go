@@ -0,0 +1,250 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+const (
+	mailServerConfigDir = "/tmp/docker-mailserver"
+
+	relaySASLPasswordFile = "postfix-sasl-password.cf"
+	relayMapFile          = "postfix-relaymap.cf"
+	userPatchesFile       = "user-patches.sh"
+	fetchmailFile         = "fetchmail.cf"
+	amavisFile            = "amavis.cf"
+	spamassassinRulesFile = "spamassassin-rules.cf"
+
+	rspamdDKIMSigningFile      = "rspamd-dkim_signing.conf"
+	rspamdRedisFile            = "rspamd-redis.conf"
+	rspamdWorkerControllerFile = "rspamd-worker-controller.inc"
+	rspamdAntivirusFile        = "rspamd-antivirus.conf"
+)
+
+// mailServerFilePaths are the paths relative to the config directory of the files
+// whose secret key cannot be the path, the others are mounted at the config directory root
+var mailServerFilePaths = map[string]string{
+	rspamdDKIMSigningFile:      "rspamd/override.d/dkim_signing.conf",
+	rspamdRedisFile:            "rspamd/override.d/redis.conf",
+	rspamdWorkerControllerFile: "rspamd/override.d/worker-controller.inc",
+	rspamdAntivirusFile:        "rspamd/override.d/antivirus.conf",
+}
+
+// Credentials are the user name and password read from a credentials secret
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// MailServerFilesSecret returns the secret containing the files rendered by the operator in the config directory.
+// It returns nil if there is no file to render.
+func MailServerFilesSecret(s *mailv1alpha1.MailServer, fetchmail []mailv1alpha1.FetchmailSource, creds map[string]Credentials, rules map[string][]byte, oidcClientSecret string) *corev1.Secret {
+	data := make(map[string][]byte)
+	if s.Spec.Relay != nil {
+		data[relaySASLPasswordFile], data[relayMapFile] = relayFiles(s.Spec.Relay, creds)
+	}
+	if len(fetchmail) != 0 {
+		data[fetchmailFile] = fetchmailConfig(fetchmail, creds)
+	}
+	if c := s.Spec.Features.SpamassassinConfig; len(c.Allow) != 0 || len(c.Deny) != 0 || len(rules) != 0 {
+		data[spamassassinRulesFile] = spamassassinRules(c, rules)
+	}
+	if s.Spec.Features.ClamavServer != nil && V(s.Spec.Features.Amavis) {
+		data[amavisFile] = amavisClamavConfig(s)
+	}
+	if s.Spec.Features.Rspamd.Enabled {
+		for k, v := range rspamdFiles(s, creds) {
+			data[k] = v
+		}
+	}
+	if s.Spec.Features.OIDC.Enabled {
+		for k, v := range oidcFiles(s, oidcClientSecret) {
+			data[k] = v
+		}
+	}
+	if s.Spec.Monitoring.Exporter.Enabled {
+		data[dovecotStatsFile] = dovecotStatsConfig()
+	}
+	if patches := mailServerUserPatches(s); len(patches) != 0 {
+		data[userPatchesFile] = []byte("#!/bin/bash\n# generated by kube-mailserver, do not edit\n" + strings.Join(patches, "\n") + "\n")
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Normalize("files", s.Spec.Domain),
+			Namespace: s.Namespace,
+			Labels:    Labels(s, "files"),
+		},
+		Data: data,
+	}
+}
+
+// mailServerFilesVolumeMounts mounts the rendered files in the config directory
+func mailServerFilesVolumeMounts(files *corev1.Secret) []corev1.VolumeMount {
+	if files == nil {
+		return nil
+	}
+	var names []string
+	for k := range files.Data {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var mounts []corev1.VolumeMount
+	for _, v := range names {
+		p, ok := mailServerFilePaths[v]
+		if !ok {
+			p = v
+		}
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      "files",
+			MountPath: path.Join(mailServerConfigDir, p),
+			SubPath:   v,
+			ReadOnly:  true,
+		})
+	}
+	return mounts
+}
+
+func mailServerFilesVolumes(files *corev1.Secret) []corev1.Volume {
+	if files == nil {
+		return nil
+	}
+	return []corev1.Volume{
+		{
+			Name: "files",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  files.Name,
+					DefaultMode: P(int32(0400)),
+				},
+			},
+		},
+	}
+}
+
+// mailServerUserPatches returns the commands run by the docker-mailserver user-patches.sh script at startup
+func mailServerUserPatches(s *mailv1alpha1.MailServer) []string {
+	patches := concat(ldapTLSPatches(s), oidcPatches(s), exporterPatches(s))
+	if r := s.Spec.Relay; r != nil {
+		switch r.TLS {
+		case mailv1alpha1.RelayTLSOpportunistic:
+			patches = append(patches, "postconf -e 'smtp_tls_security_level = may'")
+		case mailv1alpha1.RelayTLSImplicit:
+			patches = append(patches, "postconf -e 'smtp_tls_security_level = encrypt'", "postconf -e 'smtp_tls_wrappermode = yes'")
+		default:
+			patches = append(patches, "postconf -e 'smtp_tls_security_level = encrypt'")
+		}
+		if relayAuth(r) {
+			patches = append(patches,
+				"postconf -e 'smtp_sasl_auth_enable = yes'",
+				"postconf -e 'smtp_sasl_security_options = noanonymous'",
+				"postconf -e 'smtp_sasl_password_maps = texthash:/etc/postfix/sasl_passwd'",
+			)
+		}
+	}
+	if s.Spec.Features.Rspamd.Enabled && V(s.Spec.Features.Rspamd.DKIMSigning, true) {
+		// rspamd runs as _rspamd and cannot read the keys generated for opendkim
+		patches = append(patches, `for k in /tmp/docker-mailserver/opendkim/keys/*/mail.private; do install -D -o _rspamd -g _rspamd -m 0600 "$k" "`+rspamdDKIMDir+`/$(basename "$(dirname "$k")").mail.key"; done`)
+	}
+	return patches
+}
+
+// relayFiles renders the postfix-sasl-password.cf and the postfix-relaymap.cf files
+func relayFiles(r *mailv1alpha1.RelayConfig, creds map[string]Credentials) (sasl []byte, relayMap []byte) {
+	var sb, mb strings.Builder
+	if c, ok := creds[r.CredentialsSecret]; ok && r.CredentialsSecret != "" {
+		fmt.Fprintf(&sb, "%s %s:%s\n", relayHost(r.Host, r.Port), c.Username, c.Password)
+	}
+	for _, v := range r.Domains {
+		fmt.Fprintf(&mb, "@%s %s\n", v.Domain, relayHost(v.Host, v.Port))
+		if c, ok := creds[v.CredentialsSecret]; ok && v.CredentialsSecret != "" {
+			fmt.Fprintf(&sb, "%s %s:%s\n", relayHost(v.Host, v.Port), c.Username, c.Password)
+		}
+	}
+	return []byte(sb.String()), []byte(mb.String())
+}
+
+// relayAuth returns true if any relay requires authentication
+func relayAuth(r *mailv1alpha1.RelayConfig) bool {
+	if r.CredentialsSecret != "" {
+		return true
+	}
+	for _, v := range r.Domains {
+		if v.CredentialsSecret != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func relayHost(host string, port *int32) string {
+	return fmt.Sprintf("[%s]:%d", host, V(port, 587))
+}
+
+// FetchmailLabel returns the fetchmail.cf poll label of the source, used to poll it alone
+func FetchmailLabel(f *mailv1alpha1.FetchmailSource) string {
+	return f.Name
+}
+
+// fetchmailConfig renders the fetchmail.cf file, the sources are expected to be sorted
+func fetchmailConfig(sources []mailv1alpha1.FetchmailSource, creds map[string]Credentials) []byte {
+	var b strings.Builder
+	b.WriteString("# generated by kube-mailserver, do not edit\n")
+	for i := range sources {
+		f := &sources[i]
+		c, ok := creds[f.Spec.CredentialsSecret]
+		if !ok {
+			continue
+		}
+		protocol := mailv1alpha1.FetchmailProtocolIMAP
+		if f.Spec.Protocol != "" {
+			protocol = f.Spec.Protocol
+		}
+		fmt.Fprintf(&b, "poll %q via %q with proto %s", FetchmailLabel(f), f.Spec.Server, protocol)
+		if f.Spec.Port != nil {
+			fmt.Fprintf(&b, " service %d", *f.Spec.Port)
+		}
+		fmt.Fprintf(&b, "\n  user %q there with password %q is %q here", c.Username, c.Password, f.Spec.Mailbox)
+		if V(f.Spec.SSL, true) {
+			b.WriteString(" ssl sslcertck")
+		}
+		if f.Spec.Keep {
+			b.WriteString(" keep")
+		}
+		if f.Spec.FetchAll {
+			b.WriteString(" fetchall")
+		}
+		if len(f.Spec.Folders) != 0 && protocol == mailv1alpha1.FetchmailProtocolIMAP {
+			var folders []string
+			for _, v := range f.Spec.Folders {
+				folders = append(folders, fmt.Sprintf("%q", v))
+			}
+			fmt.Fprintf(&b, " folder %s", strings.Join(folders, ","))
+		}
+		b.WriteString("\n")
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+func TestFetchmailConfig(t *testing.T) {
+	source := func(name string, spec mailv1alpha1.FetchmailSourceSpec) mailv1alpha1.FetchmailSource {
+		spec.Server = "imap.example.org"
+		spec.CredentialsSecret = name
+		spec.Mailbox = "user@example.com"
+		return mailv1alpha1.FetchmailSource{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: spec}
+	}
+	creds := map[string]Credentials{
+		"imap": {Username: "user", Password: "secret"},
+		"pop3": {Username: "user", Password: "se\"cret"},
+	}
+	tests := []struct {
+		name    string
+		sources []mailv1alpha1.FetchmailSource
+		want    string
+	}{
+		{
+			name: "no sources",
+			want: "# generated by kube-mailserver, do not edit\n",
+		},
+		{
+			name:    "imap defaults",
+			sources: []mailv1alpha1.FetchmailSource{source("imap", mailv1alpha1.FetchmailSourceSpec{})},
+			want: "# generated by kube-mailserver, do not edit\n" +
+				"poll \"imap\" via \"imap.example.org\" with proto IMAP\n" +
+				"  user \"user\" there with password \"secret\" is \"user@example.com\" here ssl sslcertck\n",
+		},
+		{
+			name: "imap options",
+			sources: []mailv1alpha1.FetchmailSource{source("imap", mailv1alpha1.FetchmailSourceSpec{
+				Port:     P[int32](143),
+				SSL:      P(false),
+				Keep:     true,
+				FetchAll: true,
+				Folders:  []string{"INBOX", "Archive"},
+			})},
+			want: "# generated by kube-mailserver, do not edit\n" +
+				"poll \"imap\" via \"imap.example.org\" with proto IMAP service 143\n" +
+				"  user \"user\" there with password \"secret\" is \"user@example.com\" here keep fetchall folder \"INBOX\",\"Archive\"\n",
+		},
+		{
+			name: "pop3 ignores the folders",
+			sources: []mailv1alpha1.FetchmailSource{source("pop3", mailv1alpha1.FetchmailSourceSpec{
+				Protocol: mailv1alpha1.FetchmailProtocolPOP3,
+				Folders:  []string{"INBOX"},
+			})},
+			want: "# generated by kube-mailserver, do not edit\n" +
+				"poll \"pop3\" via \"imap.example.org\" with proto POP3\n" +
+				"  user \"user\" there with password \"se\\\"cret\" is \"user@example.com\" here ssl sslcertck\n",
+		},
+		{
+			name:    "sources without credentials are skipped",
+			sources: []mailv1alpha1.FetchmailSource{source("missing", mailv1alpha1.FetchmailSourceSpec{})},
+			want:    "# generated by kube-mailserver, do not edit\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(fetchmailConfig(tt.sources, creds)); got != tt.want {
+				t.Fatalf("expected:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRelayFiles(t *testing.T) {
+	creds := map[string]Credentials{
+		"relay":   {Username: "relay", Password: "secret"},
+		"example": {Username: "example", Password: "password"},
+	}
+	tests := []struct {
+		name     string
+		relay    mailv1alpha1.RelayConfig
+		sasl     string
+		relayMap string
+	}{
+		{
+			name:  "default relay without credentials",
+			relay: mailv1alpha1.RelayConfig{Host: "smtp.example.org"},
+		},
+		{
+			name:  "default relay with credentials",
+			relay: mailv1alpha1.RelayConfig{Host: "smtp.example.org", Port: P[int32](465), CredentialsSecret: "relay"},
+			sasl:  "[smtp.example.org]:465 relay:secret\n",
+		},
+		{
+			name: "sender domains",
+			relay: mailv1alpha1.RelayConfig{
+				Host:              "smtp.example.org",
+				CredentialsSecret: "relay",
+				Domains: []mailv1alpha1.RelayDomain{
+					{Domain: "example.com", Host: "smtp.example.com", CredentialsSecret: "example"},
+					{Domain: "example.net", Host: "smtp.example.net", Port: P[int32](25)},
+				},
+			},
+			sasl:     "[smtp.example.org]:587 relay:secret\n[smtp.example.com]:587 example:password\n",
+			relayMap: "@example.com [smtp.example.com]:587\n@example.net [smtp.example.net]:25\n",
+		},
+		{
+			name:  "missing credentials",
+			relay: mailv1alpha1.RelayConfig{Host: "smtp.example.org", CredentialsSecret: "missing"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sasl, relayMap := relayFiles(&tt.relay, creds)
+			if string(sasl) != tt.sasl {
+				t.Fatalf("expected sasl:\n%s\ngot:\n%s", tt.sasl, sasl)
+			}
+			if string(relayMap) != tt.relayMap {
+				t.Fatalf("expected relay map:\n%s\ngot:\n%s", tt.relayMap, relayMap)
+			}
+		})
+	}
+}
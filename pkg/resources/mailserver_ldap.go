@@ -0,0 +1,90 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+const ldapDovecotUserAttrs = "=uid=5000,=gid=5000,=user=%{ldap:mail},=mail=maildir:/var/mail/%d/%n/,=home=/var/mail/%d/%n/,"
+
+// ldapPresets are the default filters of the supported directory schemas
+var ldapPresets = map[mailv1alpha1.LDAPPreset]mailv1alpha1.LDAPFilters{
+	mailv1alpha1.LDAPPresetActiveDirectory: {
+		User:             "(mail=%s)",
+		Group:            "(&(objectclass=group)(mail=%s))",
+		Alias:            "(&(objectClass=user)(otherMailbox=%s))",
+		Domain:           "(mail=*@%s)",
+		DovecotUser:      "(mail=%u)",
+		DovecotPass:      "(mail=%u)",
+		DovecotUserAttrs: ldapDovecotUserAttrs,
+		SASL:             "(mail=%u)",
+	},
+	// the PostfixBookMailAccount schema used by the docker-mailserver documentation
+	mailv1alpha1.LDAPPresetOpenLDAP: {
+		User:             "(&(objectClass=PostfixBookMailAccount)(mail=%s)(mailEnabled=TRUE))",
+		Group:            "(&(objectClass=PostfixBookMailForward)(mailGroupMember=%s)(mailEnabled=TRUE))",
+		Alias:            "(&(objectClass=PostfixBookMailAccount)(mailAlias=%s)(mailEnabled=TRUE))",
+		Domain:           "(&(|(mail=*@%s)(mailAlias=*@%s)(mailGroupMember=*@%s))(mailEnabled=TRUE))",
+		DovecotUser:      "(&(objectClass=PostfixBookMailAccount)(mail=%u))",
+		DovecotPass:      "(&(objectClass=PostfixBookMailAccount)(mail=%u))",
+		DovecotUserAttrs: ldapDovecotUserAttrs,
+		SASL:             "(&(objectClass=PostfixBookMailAccount)(mail=%u))",
+	},
+	mailv1alpha1.LDAPPresetFreeIPA: {
+		User:             "(&(objectClass=inetOrgPerson)(mail=%s)(!(nsAccountLock=TRUE)))",
+		Group:            "(&(objectClass=ipausergroup)(mail=%s))",
+		Alias:            "(&(objectClass=inetOrgPerson)(mailAlternateAddress=%s)(!(nsAccountLock=TRUE)))",
+		Domain:           "(&(objectClass=inetOrgPerson)(|(mail=*@%s)(mailAlternateAddress=*@%s)))",
+		DovecotUser:      "(&(objectClass=inetOrgPerson)(mail=%u)(!(nsAccountLock=TRUE)))",
+		DovecotPass:      "(&(objectClass=inetOrgPerson)(mail=%u)(!(nsAccountLock=TRUE)))",
+		DovecotUserAttrs: ldapDovecotUserAttrs,
+		SASL:             "(&(objectClass=inetOrgPerson)(mail=%u)(!(nsAccountLock=TRUE)))",
+	},
+	// lldap has no alias nor group mail attribute, the users addresses are the only ones
+	mailv1alpha1.LDAPPresetLLDAP: {
+		User:             "(&(objectClass=person)(mail=%s))",
+		Group:            "(&(objectClass=groupOfUniqueNames)(cn=%s))",
+		Alias:            "(&(objectClass=person)(mail=%s))",
+		Domain:           "(&(objectClass=person)(mail=*@%s))",
+		DovecotUser:      "(&(objectClass=person)(mail=%u))",
+		DovecotPass:      "(&(objectClass=person)(mail=%u))",
+		DovecotUserAttrs: ldapDovecotUserAttrs,
+		SASL:             "(&(objectClass=person)(mail=%u))",
+	},
+}
+
+// LDAPFilters returns the preset filters with the user overrides applied
+func LDAPFilters(c mailv1alpha1.LDAPConfig) mailv1alpha1.LDAPFilters {
+	f, ok := ldapPresets[c.Preset]
+	if !ok {
+		f = ldapPresets[mailv1alpha1.LDAPPresetActiveDirectory]
+	}
+	str := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	str(&f.User, c.UserFilter)
+	str(&f.User, c.Filters.User)
+	str(&f.Group, c.Filters.Group)
+	str(&f.Alias, c.Filters.Alias)
+	str(&f.Domain, c.Filters.Domain)
+	str(&f.DovecotUser, c.Filters.DovecotUser)
+	str(&f.DovecotPass, c.Filters.DovecotPass)
+	str(&f.DovecotUserAttrs, c.Filters.DovecotUserAttrs)
+	str(&f.SASL, c.Filters.SASL)
+	return f
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"reflect"
+	"testing"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+func TestLDAPFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		config mailv1alpha1.LDAPConfig
+		want   mailv1alpha1.LDAPFilters
+	}{
+		{
+			name: "default preset",
+			want: ldapPresets[mailv1alpha1.LDAPPresetActiveDirectory],
+		},
+		{
+			name:   "unknown preset",
+			config: mailv1alpha1.LDAPConfig{Preset: "unknown"},
+			want:   ldapPresets[mailv1alpha1.LDAPPresetActiveDirectory],
+		},
+		{
+			name:   "openldap preset",
+			config: mailv1alpha1.LDAPConfig{Preset: mailv1alpha1.LDAPPresetOpenLDAP},
+			want:   ldapPresets[mailv1alpha1.LDAPPresetOpenLDAP],
+		},
+		{
+			name:   "deprecated user filter",
+			config: mailv1alpha1.LDAPConfig{Preset: mailv1alpha1.LDAPPresetLLDAP, UserFilter: "(uid=%s)"},
+			want: func() mailv1alpha1.LDAPFilters {
+				f := ldapPresets[mailv1alpha1.LDAPPresetLLDAP]
+				f.User = "(uid=%s)"
+				return f
+			}(),
+		},
+		{
+			name: "filters override the preset and the deprecated user filter",
+			config: mailv1alpha1.LDAPConfig{
+				Preset:     mailv1alpha1.LDAPPresetFreeIPA,
+				UserFilter: "(uid=%s)",
+				Filters: mailv1alpha1.LDAPFilters{
+					User: "(mail=%s)",
+					SASL: "(uid=%U)",
+				},
+			},
+			want: func() mailv1alpha1.LDAPFilters {
+				f := ldapPresets[mailv1alpha1.LDAPPresetFreeIPA]
+				f.User = "(mail=%s)"
+				f.SASL = "(uid=%U)"
+				return f
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LDAPFilters(tt.config); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
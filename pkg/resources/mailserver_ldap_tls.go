@@ -0,0 +1,134 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"fmt"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+const (
+	// ldapTLSDir is where the LDAP CA bundle and client certificate are mounted
+	ldapTLSDir = "/etc/mailserver/ldap"
+	// ldapClientKey is the client key copied at startup to be readable by the postfix lookups
+	ldapClientKey = "/etc/ldap/mailserver-client.key"
+	// ldapDovecotClientKey is the client key copy readable by the dovecot auth process, which does not run as root
+	ldapDovecotClientKey = "/etc/ldap/mailserver-client-dovecot.key"
+)
+
+var (
+	ldapCAFile   = path.Join(ldapTLSDir, "ca.crt")
+	ldapCertFile = path.Join(ldapTLSDir, "tls.crt")
+)
+
+// mailServerLDAPTLSVolumes projects the LDAP CA bundle and client certificate in the LDAP TLS directory
+func mailServerLDAPTLSVolumes(s *mailv1alpha1.MailServer) ([]corev1.Volume, []corev1.VolumeMount) {
+	l := s.Spec.Features.LDAP
+	if !l.Enabled || (l.TLS.CA == nil && l.TLS.ClientCertSecret == "") {
+		return nil, nil
+	}
+	var sources []corev1.VolumeProjection
+	if ca := l.TLS.CA; ca != nil {
+		switch {
+		case ca.Secret != nil:
+			sources = append(sources, corev1.VolumeProjection{
+				Secret: &corev1.SecretProjection{
+					LocalObjectReference: corev1.LocalObjectReference{Name: ca.Secret.Name},
+					Items:                []corev1.KeyToPath{{Key: ca.Secret.Key, Path: "ca.crt"}},
+				},
+			})
+		case ca.ConfigMap != nil:
+			sources = append(sources, corev1.VolumeProjection{
+				ConfigMap: &corev1.ConfigMapProjection{
+					LocalObjectReference: corev1.LocalObjectReference{Name: ca.ConfigMap.Name},
+					Items:                []corev1.KeyToPath{{Key: ca.ConfigMap.Key, Path: "ca.crt"}},
+				},
+			})
+		}
+	}
+	if l.TLS.ClientCertSecret != "" {
+		sources = append(sources, corev1.VolumeProjection{
+			Secret: &corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: l.TLS.ClientCertSecret},
+				Items: []corev1.KeyToPath{
+					{Key: corev1.TLSCertKey, Path: "tls.crt", Mode: P(int32(0444))},
+					{Key: corev1.TLSPrivateKeyKey, Path: "tls.key", Mode: P(int32(0400))},
+				},
+			},
+		})
+	}
+	return []corev1.Volume{
+		{
+			Name: "ldap-tls",
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources:     sources,
+					DefaultMode: P(int32(0444)),
+				},
+			},
+		},
+	}, []corev1.VolumeMount{
+		{
+			Name:      "ldap-tls",
+			MountPath: ldapTLSDir,
+			ReadOnly:  true,
+		},
+	}
+}
+
+// ldapTLSPatches appends the TLS settings to the LDAP configuration files generated by docker-mailserver
+func ldapTLSPatches(s *mailv1alpha1.MailServer) []string {
+	l := s.Spec.Features.LDAP
+	if !l.Enabled {
+		return nil
+	}
+	postfix := []string{"tls_require_cert = yes"}
+	dovecot := []string{"tls_require_cert = demand"}
+	var sasl []string
+	if l.TLS.InsecureSkipVerify {
+		postfix = []string{"tls_require_cert = no"}
+		dovecot = []string{"tls_require_cert = never"}
+	}
+	if l.TLS.CA != nil {
+		postfix = append(postfix, "tls_ca_cert_file = "+ldapCAFile)
+		dovecot = append(dovecot, "tls_ca_cert_file = "+ldapCAFile)
+	}
+	var patches []string
+	if l.TLS.ClientCertSecret != "" {
+		// the keys are only copied at startup, the client certificate secret is part of the configuration checksum
+		// so that the pods are restarted when it is renewed
+		patches = append(patches,
+			fmt.Sprintf("install -m 0640 -g postfix %s %s", path.Join(ldapTLSDir, "tls.key"), ldapClientKey),
+			fmt.Sprintf("install -m 0640 -g dovecot %s %s", path.Join(ldapTLSDir, "tls.key"), ldapDovecotClientKey),
+		)
+		postfix = append(postfix, "tls_cert = "+ldapCertFile, "tls_key = "+ldapClientKey)
+		dovecot = append(dovecot, "tls_cert_file = "+ldapCertFile, "tls_key_file = "+ldapDovecotClientKey)
+		sasl = append(sasl, "ldap_tls_cert: "+ldapCertFile, "ldap_tls_key: "+ldapClientKey)
+	}
+	for _, v := range postfix {
+		patches = append(patches, fmt.Sprintf("for f in /etc/postfix/ldap-*.cf; do [ ! -f \"$f\" ] || echo '%s' >> \"$f\"; done", v))
+	}
+	for _, v := range dovecot {
+		patches = append(patches, fmt.Sprintf("[ ! -f /etc/dovecot/dovecot-ldap.conf.ext ] || echo '%s' >> /etc/dovecot/dovecot-ldap.conf.ext", v))
+	}
+	for _, v := range sasl {
+		patches = append(patches, fmt.Sprintf("[ ! -f /etc/saslauthd.conf ] || echo '%s' >> /etc/saslauthd.conf", v))
+	}
+	return patches
+}
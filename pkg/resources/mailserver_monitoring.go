@@ -0,0 +1,122 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+// MonitoringGroupVersion is the Prometheus Operator API group version.
+// Its resources are built as unstructured objects as the operator does not depend on the Prometheus Operator module,
+// and its CRDs may not be installed.
+var MonitoringGroupVersion = schema.GroupVersion{Group: "monitoring.coreos.com", Version: "v1"}
+
+// MailServerServiceMonitor returns the ServiceMonitor scraping the exporters through the metrics service
+func MailServerServiceMonitor(s *mailv1alpha1.MailServer) *unstructured.Unstructured {
+	c := s.Spec.Monitoring.Exporter.ServiceMonitor
+	var endpoints []interface{}
+	for _, v := range []string{"postfix-metrics", "dovecot-metrics"} {
+		e := map[string]interface{}{"port": v}
+		if c.Interval != "" {
+			e["interval"] = c.Interval
+		}
+		endpoints = append(endpoints, e)
+	}
+	o := monitoringObject(s, "ServiceMonitor", c.Labels)
+	o.Object["spec"] = map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": stringMap(Labels(s, "metrics")),
+		},
+		"endpoints": endpoints,
+	}
+	return o
+}
+
+// MailServerPrometheusRule returns the baseline alerting rules: queue growth, bounce spike and certificate expiry
+func MailServerPrometheusRule(s *mailv1alpha1.MailServer) *unstructured.Unstructured {
+	selector := fmt.Sprintf(`namespace=%q,service=%q`, s.Namespace, MailServerMetricsService(s).Name)
+	rule := func(alert, expr, duration, severity, summary string) interface{} {
+		return map[string]interface{}{
+			"alert": alert,
+			"expr":  expr,
+			"for":   duration,
+			"labels": map[string]interface{}{
+				"severity": severity,
+			},
+			"annotations": map[string]interface{}{
+				"summary": fmt.Sprintf("%s: %s", s.Spec.Domain, summary),
+			},
+		}
+	}
+	o := monitoringObject(s, "PrometheusRule", s.Spec.Monitoring.Exporter.PrometheusRule.Labels)
+	o.Object["spec"] = map[string]interface{}{
+		"groups": []interface{}{
+			map[string]interface{}{
+				"name": Normalize("mail", s.Spec.Domain),
+				"rules": []interface{}{
+					rule(
+						"MailServerQueueGrowing",
+						fmt.Sprintf(`sum(postfix_showq_message_size_bytes_count{%[1]s}) > 100 and sum(deriv(postfix_showq_message_size_bytes_count{%[1]s}[30m])) > 0`, selector),
+						"30m",
+						"warning",
+						"the Postfix queue holds more than 100 messages and keeps growing",
+					),
+					rule(
+						"MailServerBounceSpike",
+						fmt.Sprintf(`sum(rate(postfix_smtp_status_count{%[1]s,status="bounced"}[15m])) > 3 * sum(rate(postfix_smtp_status_count{%[1]s,status="bounced"}[1d] offset 15m)) and sum(increase(postfix_smtp_status_count{%[1]s,status="bounced"}[15m])) > 10`, selector),
+						"15m",
+						"warning",
+						"the bounced deliveries rate is more than three times the daily average",
+					),
+					rule(
+						"MailServerCertificateExpiring",
+						fmt.Sprintf(`min(kube_mailserver_certificate_expiry_timestamp_seconds{namespace=%q,name=%q}) - time() < 14 * 24 * 3600`, s.Namespace, s.Name),
+						"1h",
+						"critical",
+						"a mail server certificate expires in less than 14 days",
+					),
+				},
+			},
+		},
+	}
+	return o
+}
+
+func monitoringObject(s *mailv1alpha1.MailServer, kind string, extraLabels map[string]string) *unstructured.Unstructured {
+	o := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	o.SetGroupVersionKind(MonitoringGroupVersion.WithKind(kind))
+	o.SetName(Normalize("mail", s.Spec.Domain))
+	o.SetNamespace(s.Namespace)
+	labels := Labels(s, "metrics")
+	for k, v := range extraLabels {
+		labels[k] = v
+	}
+	o.SetLabels(labels)
+	return o
+}
+
+// stringMap converts the labels to the unstructured representation
+func stringMap(m map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+const (
+	dovecotOAuth2File     = "dovecot-oauth2.conf.ext"
+	dovecotAuthOAuth2File = "dovecot-auth-oauth2.conf.ext"
+
+	// oidcMechanisms are the SASL mechanisms added to Dovecot, Postfix uses them through the Dovecot SASL socket
+	oidcMechanisms = "xoauth2 oauthbearer"
+)
+
+// oidcFiles renders the Dovecot oauth2 passdb and its configuration,
+// the client credentials authenticate the introspection requests
+func oidcFiles(s *mailv1alpha1.MailServer, clientSecret string) map[string][]byte {
+	o := s.Spec.Features.OIDC
+	files := make(map[string][]byte)
+
+	var b strings.Builder
+	b.WriteString("# generated by kube-mailserver, do not edit\n")
+	b.WriteString("passdb {\n")
+	b.WriteString("  driver = oauth2\n")
+	fmt.Fprintf(&b, "  mechanisms = %s\n", oidcMechanisms)
+	fmt.Fprintf(&b, "  args = /etc/dovecot/%s\n", dovecotOAuth2File)
+	b.WriteString("}\n")
+	files[dovecotAuthOAuth2File] = []byte(b.String())
+
+	b.Reset()
+	b.WriteString("# generated by kube-mailserver, do not edit\n")
+	b.WriteString("introspection_mode = post\n")
+	fmt.Fprintf(&b, "introspection_url = %s\n", oidcIntrospectionURL(o, clientSecret))
+	fmt.Fprintf(&b, "username_attribute = %s\n", oidcUsernameClaim(o))
+	b.WriteString("active_attribute = active\n")
+	b.WriteString("active_value = true\n")
+	b.WriteString("tls_ca_cert_file = /etc/ssl/certs/ca-certificates.crt\n")
+	files[dovecotOAuth2File] = []byte(b.String())
+	return files
+}
+
+// oidcIntrospectionURL returns the introspection URL with the client credentials, used by Dovecot as basic auth
+func oidcIntrospectionURL(o mailv1alpha1.OIDCConfig, clientSecret string) string {
+	u, err := url.Parse(o.IntrospectionURL)
+	if err != nil {
+		return o.IntrospectionURL
+	}
+	u.User = url.UserPassword(o.ClientID, clientSecret)
+	return u.String()
+}
+
+// oidcPatches installs the oauth2 passdb and adds the OAuth2 mechanisms to the Dovecot authentication
+func oidcPatches(s *mailv1alpha1.MailServer) []string {
+	if !s.Spec.Features.OIDC.Enabled {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("install -m 0640 -g dovecot %s /etc/dovecot/%s", path.Join(mailServerConfigDir, dovecotOAuth2File), dovecotOAuth2File),
+		fmt.Sprintf("install -m 0644 %s /etc/dovecot/conf.d/auth-oauth2.conf.ext", path.Join(mailServerConfigDir, dovecotAuthOAuth2File)),
+		"grep -q '^!include auth-oauth2.conf.ext' /etc/dovecot/conf.d/10-auth.conf || echo '!include auth-oauth2.conf.ext' >> /etc/dovecot/conf.d/10-auth.conf",
+		fmt.Sprintf("sed -i -E '/xoauth2/! s/^(auth_mechanisms = .*)$/\\1 %s/' /etc/dovecot/conf.d/10-auth.conf", oidcMechanisms),
+	}
+}
+
+func oidcUsernameClaim(o mailv1alpha1.OIDCConfig) string {
+	if o.UsernameClaim == "" {
+		return "email"
+	}
+	return o.UsernameClaim
+}
+
+func oidcScopes(o mailv1alpha1.OIDCConfig) []string {
+	if len(o.Scopes) == 0 {
+		return []string{"openid", "email"}
+	}
+	return o.Scopes
+}
+
+// oidcEndpoint returns the endpoint, or the Keycloak one under the issuer if unset
+func oidcEndpoint(o mailv1alpha1.OIDCConfig, endpoint, name string) string {
+	if endpoint != "" {
+		return endpoint
+	}
+	return strings.TrimSuffix(o.Issuer, "/") + "/protocol/openid-connect/" + name
+}
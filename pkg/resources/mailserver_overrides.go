@@ -0,0 +1,96 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"path"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+const (
+	PostfixMainFile   = "postfix-main.cf"
+	PostfixMasterFile = "postfix-master.cf"
+	DovecotFile       = "dovecot.cf"
+	PolicydSPFFile    = "postfix-policyd-spf.conf"
+)
+
+// Overrides returns the configured overrides references indexed by file name
+func Overrides(s *mailv1alpha1.MailServer) map[string]*mailv1alpha1.ConfigMapKeyRef {
+	o := s.Spec.Overrides
+	refs := make(map[string]*mailv1alpha1.ConfigMapKeyRef)
+	for k, v := range map[string]*mailv1alpha1.ConfigMapKeyRef{
+		PostfixMainFile:   o.PostfixMain,
+		PostfixMasterFile: o.PostfixMaster,
+		DovecotFile:       o.Dovecot,
+		PolicydSPFFile:    o.PolicydSPF,
+	} {
+		if v != nil {
+			refs[k] = v
+		}
+	}
+	return refs
+}
+
+// OverridesChecksum returns a deterministic checksum of the overrides content
+func OverridesChecksum(overrides map[string][]byte) string {
+	return Checksum(overrides)
+}
+
+// MailServerOverridesSecret returns the secret containing the validated overrides mounted in the mail server,
+// it is only updated once the overrides passed the validation
+func MailServerOverridesSecret(s *mailv1alpha1.MailServer, overrides map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Normalize("overrides", s.Spec.Domain),
+			Namespace: s.Namespace,
+			Labels:    Labels(s, "overrides"),
+		},
+		Data: overrides,
+	}
+}
+
+// mailServerOverridesVolumes projects each validated override from the overrides secret
+func mailServerOverridesVolumes(s *mailv1alpha1.MailServer, overrides map[string][]byte) (volumes []corev1.Volume, mounts []corev1.VolumeMount) {
+	if len(overrides) == 0 {
+		return nil, nil
+	}
+	var names []string
+	for k := range overrides {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	volumes = append(volumes, corev1.Volume{
+		Name: "overrides",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: Normalize("overrides", s.Spec.Domain),
+			},
+		},
+	})
+	for _, v := range names {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      "overrides",
+			MountPath: path.Join(mailServerConfigDir, v),
+			SubPath:   v,
+			ReadOnly:  true,
+		})
+	}
+	return volumes, mounts
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	mv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+const (
+	// mailServerGreetingCheck checks that Postfix answers with its SMTP banner and Dovecot with its IMAP greeting
+	mailServerGreetingCheck = `check() { exec 3<>/dev/tcp/127.0.0.1/$1 || return 1; read -t 5 -r line <&3; printf '%s\r\n' "$3" >&3; exec 3>&-; [[ "$line" == "$2"* ]]; }; check 25 220 QUIT && check 143 '* OK' 'a LOGOUT'`
+	// mailServerSupervisorCheck checks that the supervisord services are running,
+	// the disabled services are reported as STOPPED and are ignored
+	mailServerSupervisorCheck = `s=$(supervisorctl status); grep -q RUNNING <<<"$s" && ! grep -qE 'FATAL|BACKOFF|EXITED|UNKNOWN' <<<"$s"`
+)
+
+func mailServerStartupProbe(s *mv1alpha1.MailServer) *corev1.Probe {
+	return mailServerProbe(mailServerGreetingCheck, s.Spec.Probes.Startup, 10, 60)
+}
+
+func mailServerReadinessProbe(s *mv1alpha1.MailServer) *corev1.Probe {
+	return mailServerProbe(mailServerGreetingCheck, s.Spec.Probes.Readiness, 10, 3)
+}
+
+func mailServerLivenessProbe(s *mv1alpha1.MailServer) *corev1.Probe {
+	return mailServerProbe(mailServerSupervisorCheck, s.Spec.Probes.Liveness, 30, 3)
+}
+
+func mailServerProbe(command string, c mv1alpha1.ProbeConfig, period, failures int32) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"/bin/bash", "-c", command},
+			},
+		},
+		InitialDelaySeconds: V(c.InitialDelaySeconds),
+		PeriodSeconds:       V(c.PeriodSeconds, period),
+		TimeoutSeconds:      V(c.TimeoutSeconds, 10),
+		FailureThreshold:    V(c.FailureThreshold, failures),
+		SuccessThreshold:    1,
+	}
+}
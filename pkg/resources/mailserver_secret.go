@@ -16,6 +16,8 @@ package resources
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -23,21 +25,31 @@ import (
 	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
 )
 
-func MailServerConfigSecret(s *mailv1alpha1.MailServer, bindDN, bindPW string) *corev1.Secret {
+func MailServerConfigSecret(s *mailv1alpha1.MailServer, bindDN, bindPW string, fetchmail bool) *corev1.Secret {
 	ldapScheme := "ldaps"
 	if s.Spec.Features.LDAP.StartTLS {
 		ldapScheme = "ldap"
 	}
+	ldapHost := s.Spec.Features.LDAP.Host
+	if s.Spec.Features.LDAP.Port != 0 {
+		ldapHost = fmt.Sprintf("%s:%d", ldapHost, s.Spec.Features.LDAP.Port)
+	}
+	filters := LDAPFilters(s.Spec.Features.LDAP)
 	config := MailServerConfig{
 		OverrideHostname:              "mail." + s.Spec.Domain,
 		OneDir:                        P(true),
 		AccountProvisioner:            AccountProvisionerFile,
-		PostmasterAddress:             "postmaster@" + s.Spec.Domain,
+		PostmasterAddress:             PostmasterAddress(s),
 		SSLType:                       "manual",
 		SpoofProtection:               V(s.Spec.Features.SpoofProtection),
 		EnablePOP3:                    V(s.Spec.Features.POP3),
-		EnableClamav:                  V(s.Spec.Features.Clamav),
+		EnableClamav:                  V(s.Spec.Features.Clamav) && s.Spec.Features.ClamavServer == nil,
 		EnableAmavis:                  V(s.Spec.Features.Amavis),
+		EnableOpenDKIM:                !s.Spec.Features.Rspamd.Enabled,
+		EnableOpenDMARC:               !s.Spec.Features.Rspamd.Enabled,
+		EnablePolicydSPF:              !s.Spec.Features.Rspamd.Enabled,
+		EnableRspamd:                  s.Spec.Features.Rspamd.Enabled,
+		EnableRspamdRedis:             false,
 		EnableFail2ban:                V(s.Spec.Features.Fail2ban),
 		EnableManageSieve:             V(s.Spec.Features.ManageSieve),
 		PostscreenAction:              "enforce",
@@ -68,17 +80,18 @@ func MailServerConfigSecret(s *mailv1alpha1.MailServer, bindDN, bindPW string) *
 		SAKill:                        "6.31",
 		SASpamSubject:                 "***SPAM*****",
 
-		EnableFetchmail: false,
+		EnableFetchmail: fetchmail,
 
 		EnablePostgrey: V(s.Spec.Features.Postgrey),
 		PostgreyDelay:  "300",
 		PostgreyMaxAge: "35",
 		PostgreyText:   "Delayed by postgrey",
 
-		EnableSRS:         false,
-		SRSSenderClasses:  "",
-		SRSExcludeDomains: "",
-		SRSSecret:         "",
+		EnableSRS:         s.Spec.Features.SRS.Enabled,
+		SRSSenderClasses:  srsSenderClasses(s.Spec.Features.SRS.SenderClasses),
+		SRSExcludeDomains: strings.Join(s.Spec.Features.SRS.ExcludeDomains, ","),
+		// the secret is passed from the srs secret to the container environment
+		SRSSecret: "",
 
 		DefaultRelayHost: "",
 		RelayHost:        "",
@@ -88,42 +101,48 @@ func MailServerConfigSecret(s *mailv1alpha1.MailServer, bindDN, bindPW string) *
 
 		EnableLdap:            s.Spec.Features.LDAP.Enabled,
 		LdapStartTLS:          s.Spec.Features.LDAP.StartTLS,
-		LdapServerHost:        fmt.Sprintf("%s://%s", ldapScheme, s.Spec.Features.LDAP.Host),
+		LdapServerHost:        fmt.Sprintf("%s://%s", ldapScheme, ldapHost),
 		LdapSearchBase:        s.Spec.Features.LDAP.SearchBase,
 		LdapBindDN:            bindDN,
 		LdapBindPW:            bindPW,
-		LdapQueryFilterUser:   "(mail=%s)",
-		LdapQueryFilterGroup:  "(&(objectclass=group)(mail=%s))",
-		LdapQueryFilterAlias:  "(&(objectClass=user)(otherMailbox=%s))",
-		LdapQueryFilterDomain: "(mail=*@%s)",
+		LdapQueryFilterUser:   filters.User,
+		LdapQueryFilterGroup:  filters.Group,
+		LdapQueryFilterAlias:  filters.Alias,
+		LdapQueryFilterDomain: filters.Domain,
 
 		DovecotTLS:           true,
-		DovecotHosts:         s.Spec.Features.LDAP.Host,
+		DovecotHosts:         ldapHost,
 		DovecotLdapVersion:   "3",
-		DovecotUserFilter:    "(mail=%u)",
-		DovecotPassFilter:    "(mail=%u)",
+		DovecotUserFilter:    filters.DovecotUser,
+		DovecotPassFilter:    filters.DovecotPass,
 		DovecotMailboxFormat: "",
 		DovecotAuthBind:      true,
 		DovecotScope:         "subtree",
-		DovecotUserAttrs:     "=uid=5000,=gid=5000,=user=%{ldap:mail},=mail=maildir:/var/mail/%d/%n/,=home=/var/mail/%d/%n/,",
+		DovecotUserAttrs:     filters.DovecotUserAttrs,
 
 		EnableSASLAuthd:            false,
 		SASLAuthdMechanisms:        "ldap",
 		SASLAuthdMechOptions:       "",
-		SASLAuthdLdapServer:        fmt.Sprintf("%s://%s", ldapScheme, s.Spec.Features.LDAP.Host),
+		SASLAuthdLdapServer:        fmt.Sprintf("%s://%s", ldapScheme, ldapHost),
 		SASLAuthdLdapBindDn:        bindDN,
 		SASLAuthdLdapPassword:      bindPW,
 		SASLAuthdLdapSearchBase:    s.Spec.Features.LDAP.SearchBase,
-		SASLAuthdLdapFilter:        "(mail=%s)",
+		SASLAuthdLdapFilter:        filters.SASL,
 		SASLAuthdLdapStartTls:      s.Spec.Features.LDAP.StartTLS,
-		SASLAuthdLdapTlsCheckPeer:  false,
-		SASLAuthdLdapTlsCacertFile: "",
+		SASLAuthdLdapTlsCheckPeer:  !s.Spec.Features.LDAP.TLS.InsecureSkipVerify,
+		SASLAuthdLdapTlsCacertFile: ldapCAFileIfSet(s),
 		SASLAuthdLdapTlsCacertDir:  "",
 		SASLAuthdLdapPasswordAttr:  "",
 		SASLPasswd:                 "",
 		SASLAuthdLdapAuthMethod:    "",
 		SASLAuthdLdapMech:          "",
 	}
+	if s.Spec.Relay != nil {
+		config.RelayHost = s.Spec.Relay.Host
+		config.RelayPort = int(V(s.Spec.Relay.Port, 587))
+		config.DefaultRelayHost = relayHost(s.Spec.Relay.Host, s.Spec.Relay.Port)
+	}
+	mergeConfig(&config, s.Spec.Config)
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      Normalize("config", s.Spec.Domain),
@@ -133,3 +152,84 @@ func MailServerConfigSecret(s *mailv1alpha1.MailServer, bindDN, bindPW string) *
 		Data: config.ToMap(),
 	}
 }
+
+func srsSenderClasses(classes []mailv1alpha1.SRSSenderClass) string {
+	var out []string
+	for _, v := range classes {
+		out = append(out, string(v))
+	}
+	return strings.Join(out, ",")
+}
+
+// mergeConfig overrides the operator defaults with the user defined values
+func mergeConfig(config *MailServerConfig, c mailv1alpha1.Config) {
+	str := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	num := func(dst *string, v *int) {
+		if v != nil {
+			*dst = strconv.Itoa(*v)
+		}
+	}
+	size := func(dst *string, v *int64) {
+		if v != nil {
+			*dst = strconv.FormatInt(*v, 10)
+		}
+	}
+	score := func(dst *string, v *mailv1alpha1.SpamScore) {
+		if v != nil {
+			*dst = string(*v)
+		}
+	}
+	if c.LogLevel != "" {
+		config.LogLevel = LogLevel(c.LogLevel)
+	}
+	if c.SupervisorLogLevel != "" {
+		config.SupervisorLoglevel = LogLevel(c.SupervisorLogLevel)
+	}
+	config.EnableUpdateCheck = V(c.EnableUpdateCheck, config.EnableUpdateCheck)
+	str(&config.UpdateCheckInterval, c.UpdateCheckInterval)
+	str(&config.PermitDocker, c.PermitDocker)
+	str(&config.TZ, c.TZ)
+	str(&config.TLSLevel, c.TLSLevel)
+	num(&config.AmavisLoglevel, c.AmavisLogLevel)
+	config.EnableDNSBL = V(c.EnableDNSBL, config.EnableDNSBL)
+	str(&config.Fail2banBlockType, c.Fail2banBlockType)
+	str(&config.PostscreenAction, c.PostscreenAction)
+	str(&config.ClamavMessageSizeLimit, c.ClamavMessageSizeLimit)
+	num(&config.VirusMailsDeleteDelay, c.VirusMailsDeleteDelay)
+	size(&config.PostfixMailboxSizeLimit, c.PostfixMailboxSizeLimit)
+	size(&config.PostfixMessageSizeLimit, c.PostfixMessageSizeLimit)
+	str(&config.PflogsummTrigger, c.PflogsummTrigger)
+	str(&config.PflogsummRecipient, c.PflogsummRecipient)
+	str(&config.PflogsummSender, c.PflogsummSender)
+	str(&config.LogwatchInterval, c.LogwatchInterval)
+	str(&config.LogwatchRecipient, c.LogwatchRecipient)
+	str(&config.LogwatchSender, c.LogwatchSender)
+	str(&config.ReportRecipient, c.ReportRecipient)
+	str(&config.ReportSender, c.ReportSender)
+	str(&config.LogrotateInterval, c.LogrotateInterval)
+	str(&config.PostfixInetProtocols, c.PostfixInetProtocols)
+	str(&config.DovecotInetProtocols, c.DovecotInetProtocols)
+	str(&config.DovecotMailboxFormat, c.DovecotMailboxFormat)
+	config.SpamassassinSpamToInbox = V(c.SpamassassinSpamToInbox, config.SpamassassinSpamToInbox)
+	config.MoveSpamToJunk = V(c.MoveSpamToJunk, config.MoveSpamToJunk)
+	score(&config.SATag, c.SATag)
+	score(&config.SATag2, c.SATag2)
+	score(&config.SAKill, c.SAKill)
+	config.SASpamSubject = V(c.SASpamSubject, config.SASpamSubject)
+	num(&config.FetchmailPoll, c.FetchmailPoll)
+	num(&config.PostgreyDelay, c.PostgreyDelay)
+	num(&config.PostgreyMaxAge, c.PostgreyMaxAge)
+	str(&config.PostgreyText, c.PostgreyText)
+	num(&config.PostgreyAutoWhitelistClients, c.PostgreyAutoWhitelistClients)
+}
+
+func ldapCAFileIfSet(s *mailv1alpha1.MailServer) string {
+	if s.Spec.Features.LDAP.TLS.CA == nil {
+		return ""
+	}
+	return ldapCAFile
+}
@@ -35,48 +35,64 @@ func MailServerService(s *mv1alpha1.MailServer) *corev1.Service {
 			LoadBalancerIP:        string(V(s.Spec.LoadBalancerIP)),
 			LoadBalancerClass:     s.Spec.LoadBalancerClass,
 			ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
-			Ports: []corev1.ServicePort{
-				{
-					Name:       "smtp",
-					Port:       25,
-					TargetPort: intstr.IntOrString{IntVal: 25},
-				},
-				{
-					Name:       "imap",
-					Port:       143,
-					TargetPort: intstr.IntOrString{IntVal: 143},
-				},
-				{
-					Name:       "esmtp-implicit",
-					Port:       465,
-					TargetPort: intstr.IntOrString{IntVal: 465},
-				},
-				{
-					Name:       "esmtp-explicit",
-					Port:       587,
-					TargetPort: intstr.IntOrString{IntVal: 587},
-				},
-				{
-					Name:       "imap-implicit",
-					Port:       993,
-					TargetPort: intstr.IntOrString{IntVal: 993},
-				},
-				{
-					Name:       "pop3",
-					Port:       110,
-					TargetPort: intstr.IntOrString{IntVal: 110},
-				},
-				{
-					Name:       "pop3s",
-					Port:       995,
-					TargetPort: intstr.IntOrString{IntVal: 995},
-				},
-				{
-					Name:       "sieve",
-					Port:       4190,
-					TargetPort: intstr.IntOrString{IntVal: 4190},
-				},
-			},
+			Ports:                 mailServerServicePorts(s),
+		},
+	}
+}
+
+// mailServerPorts returns the mail server ports for the enabled protocols
+func mailServerPorts(s *mv1alpha1.MailServer) []corev1.ContainerPort {
+	ports := []corev1.ContainerPort{
+		{
+			Name:          "smtp",
+			ContainerPort: 25,
+		},
+		{
+			Name:          "imap",
+			ContainerPort: 143,
+		},
+		{
+			Name:          "esmtp-implicit",
+			ContainerPort: 465,
+		},
+		{
+			Name:          "esmtp-explicit",
+			ContainerPort: 587,
 		},
+		{
+			Name:          "imap-implicit",
+			ContainerPort: 993,
+		},
+	}
+	if V(s.Spec.Features.POP3) {
+		ports = append(ports,
+			corev1.ContainerPort{
+				Name:          "pop3",
+				ContainerPort: 110,
+			},
+			corev1.ContainerPort{
+				Name:          "pop3s",
+				ContainerPort: 995,
+			},
+		)
+	}
+	if V(s.Spec.Features.ManageSieve) {
+		ports = append(ports, corev1.ContainerPort{
+			Name:          "sieve",
+			ContainerPort: 4190,
+		})
+	}
+	return ports
+}
+
+func mailServerServicePorts(s *mv1alpha1.MailServer) []corev1.ServicePort {
+	var ports []corev1.ServicePort
+	for _, v := range mailServerPorts(s) {
+		ports = append(ports, corev1.ServicePort{
+			Name:       v.Name,
+			Port:       v.ContainerPort,
+			TargetPort: intstr.IntOrString{IntVal: v.ContainerPort},
+		})
 	}
+	return ports
 }
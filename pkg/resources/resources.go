@@ -16,15 +16,22 @@ package resources
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"reflect"
+	"sort"
 
 	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	"github.com/sirupsen/logrus"
 	traefikv1alpha1 "github.com/traefik/traefik/v2/pkg/provider/kubernetes/crd/traefik/v1alpha1"
 	dnsv1alpha1 "go.linka.cloud/k8s/dns/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
 )
@@ -35,65 +42,221 @@ type Config struct {
 	Password   string
 	BindDN     string
 	BindPW     string
+	// OIDCClientSecret is the OAuth2 client secret used to authenticate the token introspection requests
+	OIDCClientSecret string
+	// Credentials are the credentials read from the secrets referenced by the relay configuration
+	// and the fetchmail sources, indexed by secret name
+	Credentials map[string]Credentials
+	// FetchmailSources are the MailServer fetchmail sources
+	FetchmailSources []mailv1alpha1.FetchmailSource
+	// Overrides are the validated configuration overrides content indexed by file name
+	Overrides map[string][]byte
+	// SpamassassinRules are the extra SpamAssassin rules read from the rules ConfigMaps,
+	// indexed by <configmap>/<key>
+	SpamassassinRules map[string][]byte
 }
 
 func (config *Config) Resources() *Resources {
 	if config.Password == "" {
 		config.Password = RandomPassword()
 	}
-	var ting TraefikIngressRoutes
-	if config.MailServer.Spec.Traefik != nil {
-		ting = TraefikIngressRoutes{
-			Route:          AutoConfigTraefikIngress(config.MailServer),
-			RouteTLS:       AutoConfigTraefikIngressTLS(config.MailServer),
-			Redirect2HTTPs: AutoConfigRedirectToHTTPS(config.MailServer),
-		}
-	}
-	return &Resources{
+	s := config.MailServer
+	files := MailServerFilesSecret(s, config.FetchmailSources, config.Credentials, config.SpamassassinRules, config.OIDCClientSecret)
+	res := &Resources{
 		MailServer: &MailServerResources{
-			Deployment:   MailServerDeploy(config.MailServer),
-			PVC:          MailServerPVC(config.MailServer),
-			Service:      MailServerService(config.MailServer),
-			CredsSecret:  MailServerCredentials(config.MailServer, config.Password),
-			ConfigSecret: MailServerConfigSecret(config.MailServer, config.BindDN, config.BindPW),
-			Cert:         MailServerCert(config.MailServer),
+			Deployment:   MailServerDeploy(s, files, config.Overrides),
+			PVC:          MailServerPVC(s),
+			Service:      MailServerService(s),
+			CredsSecret:  MailServerCredentials(s, config.Password),
+			SRSSecret:    MailServerSRSSecret(s),
+			ConfigSecret: MailServerConfigSecret(s, config.BindDN, config.BindPW, len(config.FetchmailSources) != 0),
+			FilesSecret:  files,
+			// the overrides secret is only written once the overrides are validated
+			OverridesSecret: MailServerOverridesSecret(s, config.Overrides),
+			Cert:            MailServerCert(s),
 			DNS: &MailServerDNS{
-				A:          MailServerARecord(config.MailServer, config.IP),
-				MX:         MailServerMXRecord(config.MailServer),
-				DMARC:      MailServerDMARCRecord(config.MailServer),
-				SPF:        MailServerSPFRecord(config.MailServer),
-				DKIM:       MailServerDKIMRecord(config.MailServer),
-				IMAP:       MailServerIMAPRecord(config.MailServer),
-				IMAPs:      MailServerIMAPsRecord(config.MailServer),
-				Submission: MailServerSubmissionRecord(config.MailServer),
-				POP3:       MailServerPOP3Record(config.MailServer),
-				POP3s:      MailServerPOP3sRecord(config.MailServer),
+				A:          MailServerARecord(s, config.IP),
+				MX:         MailServerMXRecord(s),
+				DMARC:      MailServerDMARCRecord(s),
+				SPF:        MailServerSPFRecord(s),
+				DKIM:       MailServerDKIMRecord(s),
+				IMAP:       MailServerIMAPRecord(s),
+				IMAPs:      MailServerIMAPsRecord(s),
+				Submission: MailServerSubmissionRecord(s),
 			},
 		},
-		AutoConfig: &AutoConfigResources{
-			Cert:                 AutoConfigCert(config.MailServer),
-			AutoDiscoverRecord:   AutoConfigSRVRecord(config.MailServer),
-			Service:              AutoConfigService(config.MailServer),
-			Deployment:           AutoConfigDeploy(config.MailServer),
-			TraefikIngressRoutes: ting,
-			Ingress:              AutoConfigIngress(config.MailServer),
-		},
+		AutoConfig: &AutoConfigResources{},
+		Rspamd:     &RspamdResources{},
+		Clamav:     &ClamavResources{},
+	}
+	if s.Spec.Features.ClamavServer != nil && V(s.Spec.Features.Clamav) {
+		res.Clamav.ConfigSecret = ClamavConfigSecret(s)
+		res.Clamav.PVC = ClamavPVC(s)
+		res.Clamav.Deployment = ClamavDeploy(s)
+		res.Clamav.Service = ClamavService(s)
+		if s.Spec.Features.ClamavServer.Cache != nil {
+			res.Clamav.Freshclam = FreshclamDeploy(s)
+		}
+		// the freshclam configuration is mounted with a subPath, which is not updated in the running pods
+		checksum := Checksum(res.Clamav.ConfigSecret.Data)
+		for _, v := range []*appsv1.Deployment{res.Clamav.Deployment, res.Clamav.Freshclam} {
+			if v != nil {
+				SetPodAnnotation(v, ConfigChecksumAnnotation, checksum)
+			}
+		}
+	}
+	if s.Spec.Features.SpamassassinConfig.Learning != nil {
+		res.MailServer.SpamLearning = SpamLearningCronJob(s)
+	}
+	if e := s.Spec.Monitoring.Exporter; e.Enabled {
+		res.MailServer.MetricsService = MailServerMetricsService(s)
+		if e.ServiceMonitor.Enabled {
+			res.MailServer.ServiceMonitor = MailServerServiceMonitor(s)
+		}
+		if e.PrometheusRule.Enabled {
+			res.MailServer.PrometheusRule = MailServerPrometheusRule(s)
+		}
 	}
+	if V(s.Spec.Features.POP3) {
+		res.MailServer.DNS.POP3 = MailServerPOP3Record(s)
+		res.MailServer.DNS.POP3s = MailServerPOP3sRecord(s)
+	}
+	if r := s.Spec.Features.Rspamd; r.Enabled {
+		if r.Redis.External == "" {
+			res.Rspamd.RedisDeployment = RspamdRedisDeploy(s)
+			res.Rspamd.RedisService = RspamdRedisService(s)
+		}
+		if r.WebUI.Enabled {
+			res.Rspamd.WebUIService = RspamdWebUIService(s)
+			res.Rspamd.WebUICert = RspamdWebUICert(s)
+			if s.Spec.Traefik != nil && s.Spec.Traefik.CRDs {
+				res.Rspamd.WebUIRouteTLS = RspamdWebUITraefikIngressTLS(s)
+			} else {
+				res.Rspamd.WebUIIngress = RspamdWebUIIngress(s)
+			}
+		}
+	}
+	if !V(s.Spec.AutoConfig.Enabled, true) {
+		return res
+	}
+	res.AutoConfig.Cert = AutoConfigCert(s)
+	res.AutoConfig.AutoDiscoverRecord = AutoConfigSRVRecord(s)
+	res.AutoConfig.Service = AutoConfigService(s)
+	res.AutoConfig.ProxySecret = AutoConfigProxySecret(s)
+	res.AutoConfig.Deployment = AutoConfigDeploy(s)
+	// nginx only reads its configuration at startup
+	SetPodAnnotation(res.AutoConfig.Deployment, ConfigChecksumAnnotation, Checksum(res.AutoConfig.ProxySecret.Data))
+	if s.Spec.Traefik != nil && s.Spec.Traefik.CRDs {
+		res.AutoConfig.TraefikIngressRoutes = TraefikIngressRoutes{
+			Route:          AutoConfigTraefikIngress(s),
+			RouteTLS:       AutoConfigTraefikIngressTLS(s),
+			Redirect2HTTPs: AutoConfigRedirectToHTTPS(s),
+		}
+	} else {
+		res.AutoConfig.Ingress = AutoConfigIngress(s)
+	}
+	return res
 }
 
 type Resources struct {
 	MailServer *MailServerResources
 	AutoConfig *AutoConfigResources
+	Rspamd     *RspamdResources
+	Clamav     *ClamavResources
+}
+
+// Objects returns the enabled resources that are created and updated by the generic reconciliation.
+func (r *Resources) Objects() []client.Object {
+	return objects(
+		r.MailServer.ConfigSecret,
+		r.MailServer.FilesSecret,
+		r.MailServer.Cert,
+		r.MailServer.PVC,
+		r.MailServer.Deployment,
+		r.MailServer.Service,
+		r.MailServer.SpamLearning,
+		r.MailServer.MetricsService,
+		r.MailServer.ServiceMonitor,
+		r.MailServer.PrometheusRule,
+		r.MailServer.DNS.MX,
+		r.MailServer.DNS.SPF,
+		r.MailServer.DNS.DMARC,
+		r.MailServer.DNS.IMAP,
+		r.MailServer.DNS.IMAPs,
+		r.MailServer.DNS.POP3,
+		r.MailServer.DNS.POP3s,
+		r.MailServer.DNS.Submission,
+		r.AutoConfig.Cert,
+		r.AutoConfig.ProxySecret,
+		r.AutoConfig.Deployment,
+		r.AutoConfig.Service,
+		r.AutoConfig.AutoDiscoverRecord,
+		r.AutoConfig.TraefikIngressRoutes.Redirect2HTTPs,
+		r.AutoConfig.TraefikIngressRoutes.Route,
+		r.AutoConfig.TraefikIngressRoutes.RouteTLS,
+		r.AutoConfig.Ingress,
+		r.Clamav.ConfigSecret,
+		r.Clamav.PVC,
+		r.Clamav.Freshclam,
+		r.Clamav.Deployment,
+		r.Clamav.Service,
+		r.Rspamd.RedisDeployment,
+		r.Rspamd.RedisService,
+		r.Rspamd.WebUIService,
+		r.Rspamd.WebUICert,
+		r.Rspamd.WebUIRouteTLS,
+		r.Rspamd.WebUIIngress,
+	)
+}
+
+// Owned returns all the resources the MailServer should own, including the ones
+// with a dedicated reconciliation like the credentials and SRS secrets, the A and the DKIM records.
+// The SRS secret is kept even when SRS is disabled as regenerating it would break the forwarded mails bounces.
+// Any other owned resource is an orphan and can be pruned.
+func (r *Resources) Owned() []client.Object {
+	return append(
+		objects(
+			r.MailServer.CredsSecret,
+			r.MailServer.SRSSecret,
+			r.MailServer.OverridesSecret,
+			r.MailServer.DNS.A,
+			r.MailServer.DNS.DKIM,
+		),
+		r.Objects()...,
+	)
+}
+
+// objects filters out the nil resources, e.g. the disabled ones
+func objects(objs ...client.Object) []client.Object {
+	var out []client.Object
+	for _, v := range objs {
+		if v == nil || reflect.ValueOf(v).IsNil() {
+			continue
+		}
+		out = append(out, v)
+	}
+	return out
 }
 
 type MailServerResources struct {
 	CredsSecret  *corev1.Secret
+	SRSSecret    *corev1.Secret
 	Deployment   *appsv1.Deployment
 	PVC          *corev1.PersistentVolumeClaim
 	Service      *corev1.Service
 	ConfigSecret *corev1.Secret
-	Cert         *cmv1.Certificate
-	DNS          *MailServerDNS
+	FilesSecret  *corev1.Secret
+	// OverridesSecret is written by the overrides validation, it is not part of the generic reconciliation
+	OverridesSecret *corev1.Secret
+	SpamLearning    *batchv1.CronJob
+	Cert            *cmv1.Certificate
+	DNS             *MailServerDNS
+
+	MetricsService *corev1.Service
+	// ServiceMonitor and PrometheusRule are Prometheus Operator resources, they are not watched
+	// as their CRDs may not be installed
+	ServiceMonitor *unstructured.Unstructured
+	PrometheusRule *unstructured.Unstructured
 }
 
 type MailServerDNS struct {
@@ -113,11 +276,29 @@ type AutoConfigResources struct {
 	Cert                 *cmv1.Certificate
 	AutoDiscoverRecord   *dnsv1alpha1.DNSRecord
 	Service              *corev1.Service
+	ProxySecret          *corev1.Secret
 	Deployment           *appsv1.Deployment
 	TraefikIngressRoutes TraefikIngressRoutes
 	Ingress              *networkingv1.Ingress
 }
 
+type ClamavResources struct {
+	ConfigSecret *corev1.Secret
+	PVC          *corev1.PersistentVolumeClaim
+	Freshclam    *appsv1.Deployment
+	Deployment   *appsv1.Deployment
+	Service      *corev1.Service
+}
+
+type RspamdResources struct {
+	RedisDeployment *appsv1.Deployment
+	RedisService    *corev1.Service
+	WebUIService    *corev1.Service
+	WebUICert       *cmv1.Certificate
+	WebUIRouteTLS   *traefikv1alpha1.IngressRoute
+	WebUIIngress    *networkingv1.Ingress
+}
+
 type TraefikIngressRoutes struct {
 	Route          *traefikv1alpha1.IngressRoute
 	RouteTLS       *traefikv1alpha1.IngressRoute
@@ -131,3 +312,20 @@ func RandomPassword() string {
 	}
 	return base64.RawStdEncoding.EncodeToString(password)
 }
+
+// Checksum returns a deterministic checksum of the named inputs
+func Checksum(inputs map[string][]byte) string {
+	var names []string
+	for k := range inputs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, v := range names {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+		h.Write(inputs[v])
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+const (
+	// rspamdDKIMDir is where the DKIM keys are copied with the rspamd ownership
+	rspamdDKIMDir = "/var/lib/rspamd/dkim"
+	// RspamdWebUIPort is the rspamd controller worker port serving the web interface
+	RspamdWebUIPort = 11334
+)
+
+// rspamdFiles renders the rspamd override.d configuration files
+func rspamdFiles(s *mailv1alpha1.MailServer, creds map[string]Credentials) map[string][]byte {
+	r := s.Spec.Features.Rspamd
+	files := make(map[string][]byte)
+
+	var b strings.Builder
+	b.WriteString("# generated by kube-mailserver, do not edit\n")
+	if V(r.DKIMSigning, true) {
+		b.WriteString("enabled = true;\n")
+		b.WriteString("sign_authenticated = true;\n")
+		b.WriteString("sign_local = true;\n")
+		b.WriteString("use_domain = \"header\";\n")
+		b.WriteString("use_redis = false;\n")
+		b.WriteString("use_esld = true;\n")
+		b.WriteString("selector = \"mail\";\n")
+		fmt.Fprintf(&b, "path = \"%s/$domain.$selector.key\";\n", rspamdDKIMDir)
+	} else {
+		b.WriteString("enabled = false;\n")
+	}
+	files[rspamdDKIMSigningFile] = []byte(b.String())
+
+	b.Reset()
+	b.WriteString("# generated by kube-mailserver, do not edit\n")
+	fmt.Fprintf(&b, "servers = %q;\n", RspamdRedisAddress(s))
+	if c, ok := creds[r.Redis.PasswordSecret]; ok && r.Redis.PasswordSecret != "" {
+		fmt.Fprintf(&b, "password = %q;\n", c.Password)
+	}
+	files[rspamdRedisFile] = []byte(b.String())
+
+	if c, ok := creds[r.WebUI.PasswordSecret]; ok && r.WebUI.Enabled && r.WebUI.PasswordSecret != "" {
+		b.Reset()
+		b.WriteString("# generated by kube-mailserver, do not edit\n")
+		fmt.Fprintf(&b, "bind_socket = \"*:%d\";\n", RspamdWebUIPort)
+		fmt.Fprintf(&b, "password = %q;\n", c.Password)
+		files[rspamdWorkerControllerFile] = []byte(b.String())
+	}
+	if s.Spec.Features.ClamavServer != nil {
+		b.Reset()
+		b.WriteString("# generated by kube-mailserver, do not edit\n")
+		b.WriteString("clamav {\n")
+		b.WriteString("  scan_mime_parts = true;\n")
+		b.WriteString("  symbol = \"CLAM_VIRUS\";\n")
+		b.WriteString("  type = \"clamav\";\n")
+		b.WriteString("  action = \"reject\";\n")
+		fmt.Fprintf(&b, "  servers = %q;\n", ClamavAddress(s))
+		b.WriteString("}\n")
+		files[rspamdAntivirusFile] = []byte(b.String())
+	}
+	return files
+}
+
+// RspamdRedisAddress returns the external Redis address or the operator managed Redis service address
+func RspamdRedisAddress(s *mailv1alpha1.MailServer) string {
+	if s.Spec.Features.Rspamd.Redis.External != "" {
+		return s.Spec.Features.Rspamd.Redis.External
+	}
+	return fmt.Sprintf("%s:6379", Normalize("redis", s.Spec.Domain))
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+// RspamdRedisDeploy returns the operator managed Redis deployment used by rspamd, the data lives in an emptyDir
+func RspamdRedisDeploy(s *mailv1alpha1.MailServer) *appsv1.Deployment {
+	d := s.Spec.Features.Rspamd.Redis.Deployment
+	if d.Image == "" {
+		d.Image = "docker.io/library/redis:7-alpine"
+	}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Normalize("redis", s.Spec.Domain),
+			Namespace: s.Namespace,
+			Labels:    Labels(s, "redis"),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: P(int32(1)),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: Labels(s, "redis"),
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: Labels(s, "redis"),
+				},
+				Spec: corev1.PodSpec{
+					Affinity:                  d.Affinity,
+					TopologySpreadConstraints: d.TopologySpreadConstraints,
+					Tolerations:               d.Tolerations,
+					NodeSelector:              d.NodeSelector,
+					RestartPolicy:             corev1.RestartPolicyAlways,
+					Containers: []corev1.Container{
+						{
+							Name:      "redis",
+							Image:     d.Image,
+							Args:      []string{"--save", "", "--appendonly", "no"},
+							Resources: d.Resources,
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          "redis",
+									ContainerPort: 6379,
+									Protocol:      corev1.ProtocolTCP,
+								},
+							},
+							ReadinessProbe: &corev1.Probe{
+								ProbeHandler: corev1.ProbeHandler{
+									Exec: &corev1.ExecAction{
+										Command: []string{"redis-cli", "ping"},
+									},
+								},
+								PeriodSeconds: 10,
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "data",
+									MountPath: "/data",
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "data",
+							VolumeSource: corev1.VolumeSource{
+								EmptyDir: &corev1.EmptyDirVolumeSource{},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func RspamdRedisService(s *mailv1alpha1.MailServer) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Normalize("redis", s.Spec.Domain),
+			Namespace: s.Namespace,
+			Labels:    Labels(s, "redis-service"),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: Labels(s, "redis"),
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "redis",
+					Port:       6379,
+					TargetPort: intstr.FromInt(6379),
+				},
+			},
+		},
+	}
+}
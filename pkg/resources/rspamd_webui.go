@@ -0,0 +1,151 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	traefikv1alpha1 "github.com/traefik/traefik/v2/pkg/provider/kubernetes/crd/traefik/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+// The rspamd web interface is served by the mail server pods, the authentication is done by rspamd
+// with the password from the web interface password secret.
+
+func RspamdWebUIService(s *mailv1alpha1.MailServer) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Normalize("rspamd", s.Spec.Domain),
+			Namespace: s.Namespace,
+			Labels:    Labels(s, "rspamd-service"),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: Labels(s, "server"),
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "http",
+					Port:       80,
+					TargetPort: intstr.FromInt(RspamdWebUIPort),
+				},
+			},
+		},
+	}
+}
+
+func RspamdWebUICert(s *mailv1alpha1.MailServer) *cmv1.Certificate {
+	return &cmv1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Normalize("rspamd", s.Spec.Domain),
+			Namespace: s.Namespace,
+			Labels:    Labels(s, "rspamd-certs"),
+		},
+		Spec: cmv1.CertificateSpec{
+			CommonName: "rspamd." + s.Spec.Domain,
+			DNSNames: []string{
+				"rspamd." + s.Spec.Domain,
+			},
+			SecretName: Normalize("rspamd", s.Spec.Domain, "tls"),
+			IssuerRef:  s.Spec.IssuerRef,
+		},
+	}
+}
+
+func RspamdWebUIIngress(s *mailv1alpha1.MailServer) *networkingv1.Ingress {
+	host := "rspamd." + s.Spec.Domain
+	annotations := make(map[string]string)
+	if s.Spec.Traefik != nil {
+		annotations[TraefikIngressRouterEntryPoints] = s.Spec.Traefik.Entrypoints.HTTPS
+		annotations[TraefikIngressRouterTLS] = "true"
+	}
+	for k, v := range s.Spec.Features.Rspamd.WebUI.Ingress.Annotations {
+		annotations[k] = v
+	}
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        Normalize("rspamd", s.Spec.Domain),
+			Namespace:   s.Namespace,
+			Labels:      Labels(s, "rspamd-ingress"),
+			Annotations: annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: P(networkingv1.PathTypeImplementationSpecific),
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: Normalize("rspamd", s.Spec.Domain),
+											Port: networkingv1.ServiceBackendPort{
+												Number: 80,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			TLS: []networkingv1.IngressTLS{
+				{
+					Hosts:      []string{host},
+					SecretName: Normalize("rspamd", s.Spec.Domain, "tls"),
+				},
+			},
+		},
+	}
+}
+
+// RspamdWebUITraefikIngressTLS only serves the web interface over HTTPS as the password is sent by the browser
+func RspamdWebUITraefikIngressTLS(s *mailv1alpha1.MailServer) *traefikv1alpha1.IngressRoute {
+	return &traefikv1alpha1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Normalize("rspamd", s.Spec.Domain, "tls"),
+			Namespace: s.Namespace,
+			Labels:    Labels(s, "rspamd-https-route"),
+		},
+		Spec: traefikv1alpha1.IngressRouteSpec{
+			EntryPoints: []string{
+				s.Spec.Traefik.Entrypoints.HTTPS,
+			},
+			Routes: []traefikv1alpha1.Route{
+				{
+					Match: "Host(`rspamd." + s.Spec.Domain + "`)",
+					Kind:  "Rule",
+					Services: []traefikv1alpha1.Service{
+						{
+							LoadBalancerSpec: traefikv1alpha1.LoadBalancerSpec{
+								Name: Normalize("rspamd", s.Spec.Domain),
+								Port: intstr.FromInt(80),
+							},
+						},
+					},
+				},
+			},
+			TLS: &traefikv1alpha1.TLS{
+				SecretName: Normalize("rspamd", s.Spec.Domain, "tls"),
+			},
+		},
+	}
+}
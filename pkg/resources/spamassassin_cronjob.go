@@ -0,0 +1,142 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+// spamLearningBayesPath is the amavis SpamAssassin Bayes database path, kept by docker-mailserver in the mail state
+const spamLearningBayesPath = "/var/mail-state/lib-amavis/.spamassassin/bayes"
+
+// SpamLearningCronJob returns the CronJob training the Bayes database from the users spam and ham folders.
+// It mounts the mail volume, which must then be ReadWriteMany.
+func SpamLearningCronJob(s *mailv1alpha1.MailServer) *batchv1.CronJob {
+	l := s.Spec.Features.SpamassassinConfig.Learning
+	schedule := l.Schedule
+	if schedule == "" {
+		schedule = "0 3 * * *"
+	}
+	return &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Normalize("spam-learning", s.Spec.Domain),
+			Namespace: s.Namespace,
+			Labels:    Labels(s, "spam-learning"),
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:                   schedule,
+			ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+			Suspend:                    P(s.Spec.Maintenance),
+			SuccessfulJobsHistoryLimit: P(int32(1)),
+			FailedJobsHistoryLimit:     P(int32(1)),
+			JobTemplate: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					BackoffLimit: P(int32(0)),
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: Labels(s, "spam-learning"),
+						},
+						Spec: corev1.PodSpec{
+							Affinity:      s.Spec.Affinity,
+							Tolerations:   s.Spec.Tolerations,
+							NodeSelector:  s.Spec.NodeSelector,
+							RestartPolicy: corev1.RestartPolicyNever,
+							Containers: []corev1.Container{
+								{
+									Name:            "sa-learn",
+									Image:           s.Spec.Image,
+									ImagePullPolicy: corev1.PullIfNotPresent,
+									Command:         []string{"/bin/bash", "-c", spamLearningScript(l)},
+									VolumeMounts: []corev1.VolumeMount{
+										{
+											Name:      "mail-data",
+											MountPath: "/var/mail",
+											SubPath:   "volumes/maildata",
+										},
+										{
+											Name:      "mail-data",
+											MountPath: "/var/mail-state",
+											SubPath:   "volumes/mailstate",
+										},
+									},
+								},
+							},
+							Volumes: []corev1.Volume{
+								{
+									Name: "mail-data",
+									VolumeSource: corev1.VolumeSource{
+										PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+											ClaimName: Normalize(s.Spec.Domain, "data"),
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// spamLearningScript learns the mails of every mailbox folders, the learned counts are printed in the job logs
+func spamLearningScript(l *mailv1alpha1.SpamLearningConfig) string {
+	spam := l.SpamFolders
+	if len(spam) == 0 {
+		spam = []string{"Junk"}
+	}
+	ham := l.HamFolders
+	if len(ham) == 0 {
+		ham = []string{"INBOX", "Archive"}
+	}
+	lines := []string{
+		"set -euo pipefail",
+		"db=" + spamLearningBayesPath,
+		`mkdir -p "$(dirname "$db")"`,
+		fmt.Sprintf(`learn() { [ -d "$2" ] || return 0; find "$2" -type f -mtime -%d -print0 | xargs -0 -r sa-learn --dbpath "$db" --no-sync "--$1"; }`, V(l.MaxAgeDays, 30)),
+		"for m in /var/mail/*/*/; do",
+	}
+	for _, v := range spam {
+		lines = append(lines, fmt.Sprintf(`  echo "learning spam from $m" %s; learn spam "$m"%s`, shellQuote(v), shellQuote(maildirFolder(v))))
+	}
+	for _, v := range ham {
+		lines = append(lines, fmt.Sprintf(`  echo "learning ham from $m" %s; learn ham "$m"%s`, shellQuote(v), shellQuote(maildirFolder(v))))
+	}
+	lines = append(lines,
+		"done",
+		`sa-learn --dbpath "$db" --sync`,
+		`chown -R amavis:amavis "$(dirname "$db")"`,
+	)
+	return strings.Join(lines, "\n")
+}
+
+// maildirFolder returns the Dovecot Maildir++ directory of the folder
+func maildirFolder(name string) string {
+	if strings.EqualFold(name, "INBOX") {
+		return "cur"
+	}
+	return "." + strings.ReplaceAll(name, "/", ".") + "/cur"
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
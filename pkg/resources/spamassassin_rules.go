@@ -0,0 +1,55 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"sort"
+	"strings"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+// spamassassinRules renders the spamassassin-rules.cf file with the senders lists followed by the extra rules
+func spamassassinRules(c mailv1alpha1.SpamassassinConfig, rules map[string][]byte) []byte {
+	var b strings.Builder
+	b.WriteString("# generated by kube-mailserver, do not edit\n")
+	for _, v := range c.Allow {
+		b.WriteString("whitelist_from " + spamassassinSender(v) + "\n")
+	}
+	for _, v := range c.Deny {
+		b.WriteString("blacklist_from " + spamassassinSender(v) + "\n")
+	}
+	var keys []string
+	for k := range rules {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString("\n# " + k + "\n")
+		b.Write(rules[k])
+		if !strings.HasSuffix(string(rules[k]), "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+// spamassassinSender returns the sender pattern, matching all the domain addresses if no local part is given
+func spamassassinSender(sender string) string {
+	if strings.Contains(sender, "@") {
+		return sender
+	}
+	return "*@" + sender
+}
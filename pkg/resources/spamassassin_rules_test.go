@@ -0,0 +1,67 @@
+// Copyright 2022 Linka Cloud  All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"testing"
+
+	mailv1alpha1 "go.linka.cloud/kube-mailserver/api/v1alpha1"
+)
+
+func TestSpamassassinRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		config mailv1alpha1.SpamassassinConfig
+		rules  map[string][]byte
+		want   string
+	}{
+		{
+			name: "empty",
+			want: "# generated by kube-mailserver, do not edit\n",
+		},
+		{
+			name: "sender lists",
+			config: mailv1alpha1.SpamassassinConfig{
+				Allow: []string{"example.org", "friend@example.net"},
+				Deny:  []string{"spam.example.com"},
+			},
+			want: "# generated by kube-mailserver, do not edit\n" +
+				"whitelist_from *@example.org\n" +
+				"whitelist_from friend@example.net\n" +
+				"blacklist_from *@spam.example.com\n",
+		},
+		{
+			name: "rules are sorted and terminated",
+			config: mailv1alpha1.SpamassassinConfig{
+				Allow: []string{"example.org"},
+			},
+			rules: map[string][]byte{
+				"rules/b.cf": []byte("score B 1.0"),
+				"rules/a.cf": []byte("score A 2.0\n"),
+			},
+			want: "# generated by kube-mailserver, do not edit\n" +
+				"whitelist_from *@example.org\n" +
+				"\n# rules/a.cf\nscore A 2.0\n" +
+				"\n# rules/b.cf\nscore B 1.0\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(spamassassinRules(tt.config, tt.rules)); got != tt.want {
+				t.Fatalf("expected:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}